@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+// CreateAppPassword func to create a password for a client that signs in with
+// Basic auth, such as a CalDAV client. It is only shown in this response.
+func CreateAppPassword(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	createAppPassword := new(models.CreateAppPassword)
+	if err := c.BodyParser(&createAppPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createAppPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	password, passwordHash, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	appPassword := new(models.AppPassword)
+	appPassword.UserId = user.ID
+	appPassword.Name = createAppPassword.Name
+	appPassword.PasswordHash = passwordHash
+	appPassword.CreatedAt = time.Now().Unix()
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).InsertOne(c.Context(), appPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":        false,
+		"message":      "App password created successfully",
+		"app_password": res.InsertedID,
+		"username":     user.Email,
+		"password":     password,
+	})
+}
+
+func GetAppPasswords(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var appPasswords []models.AppPassword
+
+	db := c.Locals("db").(*mongo.Database)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).Find(c.Context(), bson.M{"user_id": user.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &appPasswords); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	appPasswordsResponse := make([]models.GetAppPassword, 0, len(appPasswords))
+	for _, appPassword := range appPasswords {
+		appPasswordsResponse = append(appPasswordsResponse, models.GetAppPassword{
+			ID:         appPassword.ID.Hex(),
+			Name:       appPassword.Name,
+			LastUsedAt: appPassword.LastUsedAt,
+			CreatedAt:  appPassword.CreatedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":         false,
+		"app_passwords": appPasswordsResponse,
+	})
+}
+
+func DeleteAppPassword(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid app password ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).DeleteOne(c.Context(), bson.M{"_id": id, "user_id": user.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "App password not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "App password deleted successfully",
+	})
+}
@@ -0,0 +1,469 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+var errAttachmentMissing = errors.New("A file field is required")
+
+const (
+	defaultAttachmentUserQuota = 100 * 1024 * 1024
+)
+
+func UploadAttachment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Attachments must be uploaded as multipart/form-data",
+		})
+	}
+
+	// Large bodies arrive as a stream, small ones are already read
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	part, err := attachmentPart(multipart.NewReader(body, boundary))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+	defer part.Close()
+
+	db := c.Locals("db").(*mongo.Database)
+
+	quota := envSize("ATTACHMENT_USER_QUOTA", defaultAttachmentUserQuota)
+	used, err := attachmentBytes(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	maxFileSize := utils.AttachmentMaxFileSize()
+	limit := maxFileSize
+	message := "Attachment is too large, max " + strconv.FormatInt(maxFileSize, 10) + " bytes allowed"
+	if remaining := quota - used; remaining < limit {
+		limit = remaining
+		message = "Attachment quota exceeded"
+	}
+
+	if limit <= 0 {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   true,
+			"message": message,
+		})
+	}
+
+	// The type comes from the first bytes of the file, never its extension
+	contentType, content, err := utils.SniffContentType(utils.SizeLimitReader(part, limit))
+	if err == utils.ErrAttachmentTooLarge {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   true,
+			"message": message,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	bucket, err := attachmentBucket(db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	uploadStream, err := bucket.OpenUploadStream(part.FileName(), options.GridFSUpload().SetMetadata(models.AttachmentMetadata{
+		TaskId:      task.ID,
+		UserId:      user.ID,
+		ContentType: contentType,
+	}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Chunks are written as they come in, a failed upload removes the ones
+	// already stored
+	size, err := io.Copy(uploadStream, content)
+	if err != nil {
+		if err := uploadStream.Abort(); err != nil {
+			log.Printf("Aborting upload of attachment %v failed: %v\n", uploadStream.FileID, err)
+		}
+
+		if err == utils.ErrAttachmentTooLarge {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error":   true,
+				"message": message,
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	// The quota is taken before the file shows up, uploads running at the
+	// same time can not both fit into the same space
+	reserved, err := reserveAttachmentBytes(c.Context(), db, user.ID, size, quota)
+	if err != nil || !reserved {
+		if err := uploadStream.Abort(); err != nil {
+			log.Printf("Aborting upload of attachment %v failed: %v\n", uploadStream.FileID, err)
+		}
+
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   true,
+			"message": "Attachment quota exceeded",
+		})
+	}
+
+	if err := uploadStream.Close(); err != nil {
+		addAttachmentBytes(c.Context(), db, user.ID, -size)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":      false,
+		"message":    "Attachment uploaded successfully",
+		"attachment": uploadStream.FileID,
+	})
+}
+
+func GetAttachments(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	var attachments []models.Attachment
+
+	opts := options.Find().SetSort(bson.D{{Key: "uploadDate", Value: 1}})
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).Find(c.Context(), fiber.Map{"metadata.task_id": task.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &attachments); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	attachmentsResponse := make([]models.GetAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		attachmentsResponse = append(attachmentsResponse, models.GetAttachment{
+			ID:          attachment.ID.Hex(),
+			Filename:    attachment.Filename,
+			ContentType: attachment.Metadata.ContentType,
+			Size:        attachment.Length,
+			UploadedBy:  attachment.Metadata.UserId.Hex(),
+			CreatedAt:   attachment.UploadDate.Unix(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":       false,
+		"attachments": attachmentsResponse,
+	})
+}
+
+func DownloadAttachment(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	attachmentID, err := primitive.ObjectIDFromHex(c.Params("attachmentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid attachment ID",
+		})
+	}
+
+	attachment := new(models.Attachment)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": attachmentID, "metadata.task_id": task.ID}).Decode(&attachment); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Attachment not found",
+		})
+	}
+
+	bucket, err := attachmentBucket(db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	downloadStream, err := bucket.OpenDownloadStream(attachment.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// The response reads the chunks as it goes and closes the stream at the end
+	utils.SetAttachmentHeaders(c, attachment.Filename, attachment.Metadata.ContentType)
+
+	return c.SendStream(downloadStream, int(attachment.Length))
+}
+
+func DeleteAttachment(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	attachmentID, err := primitive.ObjectIDFromHex(c.Params("attachmentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid attachment ID",
+		})
+	}
+
+	attachment := new(models.Attachment)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": attachmentID, "metadata.task_id": task.ID}).Decode(attachment); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Attachment not found",
+		})
+	}
+
+	bucket, err := attachmentBucket(db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	err = bucket.DeleteContext(c.Context(), attachmentID)
+	if err != nil && err != gridfs.ErrFileNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Only the request that removed the file gives its space back
+	if err == nil {
+		addAttachmentBytes(c.Context(), db, attachment.Metadata.UserId, -attachment.Length)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Attachment deleted successfully",
+	})
+}
+
+// attachmentPart func to skip ahead to the file part of a multipart upload.
+func attachmentPart(reader *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errAttachmentMissing
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// attachmentUsage func to add up the size of every attachment the user
+// uploaded to tasks that are not in the trash.
+func attachmentUsage(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"metadata.user_id": userID, "metadata.purge_at": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$length"}}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+
+	var results []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	return results[0].Total, nil
+}
+
+// attachmentBytes func to read the space the attachments of the user take up,
+// counting the uploads that are still running. The count is kept on the user
+// and started from the stored files the first time it is needed.
+func attachmentBytes(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (int64, error) {
+	users := db.Collection(os.Getenv("USER_COLLECTION"))
+
+	var user struct {
+		AttachmentBytes *int64 `bson:"attachment_bytes"`
+	}
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return 0, err
+	}
+	if user.AttachmentBytes != nil {
+		return *user.AttachmentBytes, nil
+	}
+
+	used, err := attachmentUsage(ctx, db, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Another request may have started the count in the meantime
+	if _, err := users.UpdateOne(ctx, bson.M{"_id": userID, "attachment_bytes": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"attachment_bytes": used}}); err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
+
+// reserveAttachmentBytes func to take space for an upload when it still fits
+// into the quota of the user, in one conditional update.
+func reserveAttachmentBytes(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, size, quota int64) (bool, error) {
+	if _, err := attachmentBytes(ctx, db, userID); err != nil {
+		return false, err
+	}
+
+	res, err := db.Collection(os.Getenv("USER_COLLECTION")).UpdateOne(ctx, bson.M{"_id": userID, "attachment_bytes": bson.M{"$lte": quota - size}}, bson.M{"$inc": bson.M{"attachment_bytes": size}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// addAttachmentBytes func to change the space the attachments of a user take
+// up, giving it back with a negative size.
+func addAttachmentBytes(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, size int64) {
+	if size == 0 {
+		return
+	}
+
+	if _, err := db.Collection(os.Getenv("USER_COLLECTION")).UpdateOne(ctx, bson.M{"_id": userID, "attachment_bytes": bson.M{"$exists": true}}, bson.M{"$inc": bson.M{"attachment_bytes": size}}); err != nil {
+		log.Printf("Updating the attachment usage of user %s failed: %v\n", userID.Hex(), err)
+	}
+}
+
+// attachmentSizes func to add up the size of the attachments matching the
+// filter by their uploader.
+func attachmentSizes(ctx context.Context, db *mongo.Database, filter bson.M) (map[primitive.ObjectID]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$metadata.user_id", "total": bson.M{"$sum": "$length"}}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		UserID primitive.ObjectID `bson:"_id"`
+		Total  int64              `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[primitive.ObjectID]int64, len(results))
+	for _, result := range results {
+		sizes[result.UserID] = result.Total
+	}
+
+	return sizes, nil
+}
+
+// deleteTaskAttachments func to remove the files attached to deleted tasks.
+func deleteTaskAttachments(ctx context.Context, db *mongo.Database, taskIDs ...primitive.ObjectID) error {
+	ids, err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).Distinct(ctx, "_id", bson.M{"metadata.task_id": bson.M{"$in": taskIDs}})
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	bucket, err := attachmentBucket(db)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := bucket.DeleteContext(ctx, id); err != nil && err != gridfs.ErrFileNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attachmentBucket(db *mongo.Database) (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(db, options.GridFSBucket().SetName(os.Getenv("ATTACHMENT_BUCKET")))
+}
+
+// envSize func to read a size in bytes from the environment, falling back to
+// def when it is not set or not a number.
+func envSize(key string, def int64) int64 {
+	size, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return def
+	}
+
+	return size
+}
@@ -0,0 +1,751 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const maxBatchOperations = 500
+
+// batchItem is one operation of a batch along with the writes it turns into.
+// Task is the task before an update or delete, or the new task of a create.
+type batchItem struct {
+	result models.BatchResult
+	writes []mongo.WriteModel
+	task   *models.Task
+	update map[string]interface{}
+}
+
+func (item *batchItem) fail(status int, message string) {
+	item.result.Status = status
+	item.result.Error = message
+}
+
+func (item *batchItem) failed() bool {
+	return item.result.Error != ""
+}
+
+func BatchTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	batch := new(models.BatchTasks)
+	if err := c.BodyParser(&batch); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(batch); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if (len(batch.Operations) == 0) == (batch.Filter == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Either operations or a filter with an action is required",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	operations := batch.Operations
+	if batch.Filter != nil {
+		var err error
+		if operations, err = filterOperations(c.Context(), db, user.ID, batch); err != nil {
+			if e, ok := err.(*fiber.Error); ok {
+				return c.Status(e.Code).JSON(fiber.Map{
+					"error":   true,
+					"message": e.Message,
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+	}
+
+	if batch.Atomic {
+		supported, err := transactionsSupported(c.Context(), db)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+		if !supported {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+				"error":   true,
+				"message": "Atomic batches need MongoDB to run as a replica set",
+			})
+		}
+	}
+
+	// Deleted tasks of one batch share their purge date
+	purgeAt := time.Now().Add(trashRetention())
+	batchID := primitive.NewObjectID()
+
+	items, err := prepareBatch(c.Context(), db, user, operations, purgeAt, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Atomic batches are only written when every operation is valid
+	if batch.Atomic && countFailed(items) > 0 {
+		skipBatch(items, "Batch was not applied")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Batch was not applied",
+			"results": batchResults(items),
+		})
+	}
+
+	rolledBack, err := runBatch(c.Context(), db, items, batchID, batch.Atomic)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if rolledBack {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Batch was rolled back",
+			"results": batchResults(items),
+		})
+	}
+
+	applyBatchEffects(c.Context(), db, user, items, purgeAt)
+
+	failed := countFailed(items)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":     false,
+		"results":   batchResults(items),
+		"succeeded": len(items) - failed,
+		"failed":    failed,
+	})
+}
+
+// filterOperations func to turn a filter and its action into one operation
+// for every visible task it matches.
+func filterOperations(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, batch *models.BatchTasks) ([]models.BatchOperation, error) {
+	fields, err := userCustomFields(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := utils.ParseTaskQuery(batch.Filter, fields)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	visible, err := visibleTasksFilter(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(maxBatchOperations + 1)
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, bson.M{"$and": bson.A{visible, query.Filter}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	if len(tasks) > maxBatchOperations {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Filter matches more than "+strconv.Itoa(maxBatchOperations)+" tasks")
+	}
+
+	operations := make([]models.BatchOperation, 0, len(tasks))
+	for _, task := range tasks {
+		operations = append(operations, models.BatchOperation{Op: batch.Action, ID: task.ID.Hex(), Task: batch.Update})
+	}
+
+	return operations, nil
+}
+
+// prepareBatch func to check every operation and build its writes. Problems
+// with an operation fail that item only, the error is kept for the server.
+func prepareBatch(ctx context.Context, db *mongo.Database, user *models.User, operations []models.BatchOperation, purgeAt time.Time, batchID primitive.ObjectID) ([]*batchItem, error) {
+	validate := validator.New()
+
+	items := make([]*batchItem, len(operations))
+	ids := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+
+	for i, operation := range operations {
+		item := &batchItem{result: models.BatchResult{Index: i, Op: operation.Op, ID: operation.ID}}
+		items[i] = item
+
+		if err := validate.Struct(operation); err != nil {
+			item.fail(fiber.StatusBadRequest, err.Error())
+			continue
+		}
+
+		if operation.Op == "create" {
+			continue
+		}
+
+		// Later operations would work on a stale copy of the task
+		id, _ := primitive.ObjectIDFromHex(operation.ID)
+		if seen[id] {
+			item.fail(fiber.StatusBadRequest, "Task appears more than once in the batch")
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	tasks, err := batchTasks(ctx, db, user.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		item := items[i]
+		if item.failed() {
+			continue
+		}
+
+		if operation.Op != "create" {
+			id, _ := primitive.ObjectIDFromHex(operation.ID)
+			if err := batchTaskAccess(tasks, id); err != nil {
+				item.fail(err.Code, err.Message)
+				continue
+			}
+			item.task = tasks[id].task
+		}
+
+		var err error
+		switch operation.Op {
+		case "create":
+			err = prepareBatchCreate(ctx, db, user, item, operation)
+		case "update":
+			err = prepareBatchUpdate(ctx, db, item, operation, batchID)
+		case "delete":
+			prepareBatchDelete(item, purgeAt, batchID)
+		}
+
+		switch e := err.(type) {
+		case nil:
+		case utils.FieldErrors:
+			item.fail(fiber.StatusBadRequest, e.Error())
+		case *fiber.Error:
+			item.fail(e.Code, e.Message)
+		default:
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func prepareBatchCreate(ctx context.Context, db *mongo.Database, user *models.User, item *batchItem, operation models.BatchOperation) error {
+	createTask := new(models.CreateTask)
+
+	raw, err := json.Marshal(operation.Task)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := json.Unmarshal(raw, createTask); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := validator.New().Struct(createTask); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	task, err := newTask(ctx, db, user, createTask, metadataCreate)
+	if err != nil {
+		return err
+	}
+	task.ID = primitive.NewObjectID()
+
+	item.task = task
+	item.writes = []mongo.WriteModel{mongo.NewInsertOneModel().SetDocument(task)}
+	item.result.ID = task.ID.Hex()
+	item.result.Status = fiber.StatusCreated
+
+	return nil
+}
+
+func prepareBatchUpdate(ctx context.Context, db *mongo.Database, item *batchItem, operation models.BatchOperation, batchID primitive.ObjectID) error {
+	// The parser changes the map it is given and filters share theirs
+	taskUpdate := make(map[string]interface{}, len(operation.Task))
+	for key, value := range operation.Task {
+		taskUpdate[key] = value
+	}
+
+	parsedTaskUpdate, err := parseTaskUpdate(ctx, db, item.task, taskUpdate)
+	if err != nil {
+		return err
+	}
+
+	// Tasks with open blockers can only be completed with force
+	if completing, _ := parsedTaskUpdate["completed"].(bool); completing && !item.task.Completed && !operation.Force {
+		blockers, err := openBlockers(ctx, db, item.task)
+		if err != nil {
+			return err
+		}
+		if len(blockers) > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Task is blocked by incomplete tasks")
+		}
+	}
+
+	set := bson.M{"batch_id": batchID}
+	for key, value := range parsedTaskUpdate {
+		set[key] = value
+	}
+
+	item.update = parsedTaskUpdate
+	item.writes = []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": item.task.ID, "user_id": item.task.UserId, "deleted_at": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": set, "$inc": bson.M{"version": 1}}),
+	}
+	item.result.Status = fiber.StatusOK
+
+	return nil
+}
+
+// prepareBatchDelete func to move a task to the trash and hand its subtasks
+// to its parent, like a delete without cascade.
+func prepareBatchDelete(item *batchItem, purgeAt time.Time, batchID primitive.ObjectID) {
+	task := item.task
+	now := time.Now().Unix()
+
+	children := bson.M{"$set": bson.M{"parent_id": task.ParentId, "updated_at": now}, "$inc": bson.M{"version": 1}}
+	if task.ParentId == nil {
+		children = bson.M{"$unset": bson.M{"parent_id": ""}, "$set": bson.M{"updated_at": now}, "$inc": bson.M{"version": 1}}
+	}
+
+	item.writes = []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": task.ID, "user_id": task.UserId, "deleted_at": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": bson.M{"deleted_at": now, "purge_at": purgeAt, "updated_at": now, "batch_id": batchID}, "$inc": bson.M{"version": 1}}),
+		mongo.NewUpdateManyModel().
+			SetFilter(bson.M{"parent_id": task.ID}).
+			SetUpdate(children),
+	}
+	item.result.Status = fiber.StatusOK
+}
+
+// batchTask is a task of a batch along with the role of the user on it.
+type batchTask struct {
+	task *models.Task
+	role string
+}
+
+// batchTasks func to load the tasks a batch works on along with the role of
+// the user on each of them. Missing and trashed tasks are left out.
+func batchTasks(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, ids []primitive.ObjectID) (map[primitive.ObjectID]*batchTask, error) {
+	tasks := map[primitive.ObjectID]*batchTask{}
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+
+	var found []models.Task
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	projectIDs := []primitive.ObjectID{}
+	for i := range found {
+		task := &found[i]
+		tasks[task.ID] = &batchTask{task: task}
+		if task.UserId == userID {
+			tasks[task.ID].role = models.RoleOwner
+		} else if !task.ProjectId.IsZero() {
+			projectIDs = append(projectIDs, task.ProjectId)
+		}
+	}
+
+	if len(projectIDs) == 0 {
+		return tasks, nil
+	}
+
+	var projects []models.Project
+
+	cursor, err = db.Collection(os.Getenv("PROJECTS_COLLECTION")).Find(ctx, bson.M{"_id": bson.M{"$in": projectIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	roles := map[primitive.ObjectID]string{}
+	for _, project := range projects {
+		roles[project.ID] = utils.ProjectRole(&project, userID)
+	}
+
+	for _, task := range tasks {
+		if task.role == "" {
+			task.role = roles[task.task.ProjectId]
+		}
+	}
+
+	return tasks, nil
+}
+
+// batchTaskAccess func to check the user can edit a task of the batch.
+func batchTaskAccess(tasks map[primitive.ObjectID]*batchTask, id primitive.ObjectID) *fiber.Error {
+	task, ok := tasks[id]
+	if !ok || task.role == "" {
+		return fiber.NewError(fiber.StatusNotFound, "Task not found")
+	}
+
+	if !utils.RoleAllows(task.role, models.RoleEditor) {
+		return fiber.NewError(fiber.StatusForbidden, "You do not have permission to do this")
+	}
+
+	return nil
+}
+
+// runBatch func to write a batch as bulk writes, the writes on the tasks of
+// the items first and then the writes that follow from them for the items
+// that went through. An item whose task was trashed or deleted since it was
+// checked fails instead of reporting a write that did not happen. Atomic
+// batches run in a transaction and report whether it was rolled back by a
+// failed item.
+func runBatch(ctx context.Context, db *mongo.Database, items []*batchItem, batchID primitive.ObjectID, atomic bool) (bool, error) {
+	pending := []*batchItem{}
+	for _, item := range items {
+		if !item.failed() {
+			pending = append(pending, item)
+		}
+	}
+
+	if len(pending) == 0 {
+		return false, nil
+	}
+
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	if !atomic {
+		failures, err := writeBatch(ctx, collection, pending, batchID)
+		if err != nil {
+			return false, err
+		}
+		for item, failure := range failures {
+			item.fail(failure.Code, failure.Message)
+		}
+		return false, nil
+	}
+
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	// Transactions are retried as a whole, only the last attempt counts
+	var failures map[*batchItem]*fiber.Error
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var err error
+		if failures, err = writeBatch(sc, collection, pending, batchID); err != nil {
+			return nil, err
+		}
+		if len(failures) > 0 {
+			return nil, errBatchRolledBack
+		}
+		return nil, nil
+	})
+	if len(failures) == 0 {
+		return false, err
+	}
+
+	for item, failure := range failures {
+		item.fail(failure.Code, failure.Message)
+	}
+	skipBatch(items, "Batch was rolled back")
+	return true, nil
+}
+
+var errBatchRolledBack = fiber.NewError(fiber.StatusConflict, "Batch was rolled back")
+
+// writeBatch func to run the writes of the items in two unordered bulk
+// writes. The first write of an item is the one on its task, updates and
+// deletes tag the task with the batch so the items that matched nothing can
+// be found with one query. The problems of the items are returned by item.
+func writeBatch(ctx context.Context, collection *mongo.Collection, pending []*batchItem, batchID primitive.ObjectID) (map[*batchItem]*fiber.Error, error) {
+	failures := map[*batchItem]*fiber.Error{}
+
+	writes := make([]mongo.WriteModel, 0, len(pending))
+	for _, item := range pending {
+		writes = append(writes, item.writes[0])
+	}
+
+	result, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if bulkErr, ok := err.(mongo.BulkWriteException); ok && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0 {
+		for _, writeErr := range bulkErr.WriteErrors {
+			status := fiber.StatusInternalServerError
+			if writeErr.Code == 11000 {
+				status = fiber.StatusConflict
+			}
+			failures[pending[writeErr.Index]] = fiber.NewError(status, writeErr.Message)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if int(result.InsertedCount+result.MatchedCount) < len(pending)-len(failures) {
+		if err := findMissedBatchItems(ctx, collection, pending, batchID, failures); err != nil {
+			return nil, err
+		}
+	}
+
+	writes = writes[:0]
+	for _, item := range pending {
+		if _, ok := failures[item]; !ok {
+			writes = append(writes, item.writes[1:]...)
+		}
+	}
+
+	if len(writes) > 0 {
+		if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return nil, err
+		}
+	}
+
+	return failures, nil
+}
+
+// findMissedBatchItems func to fail the updates and deletes of a batch whose
+// task does not carry the tag of the batch, their task was gone.
+func findMissedBatchItems(ctx context.Context, collection *mongo.Collection, pending []*batchItem, batchID primitive.ObjectID, failures map[*batchItem]*fiber.Error) error {
+	ids := []primitive.ObjectID{}
+	for _, item := range pending {
+		if _, ok := failures[item]; !ok && item.result.Op != "create" {
+			ids = append(ids, item.task.ID)
+		}
+	}
+
+	var written []models.Task
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "batch_id": batchID}, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := cursor.All(ctx, &written); err != nil {
+		return err
+	}
+
+	applied := map[primitive.ObjectID]bool{}
+	for _, task := range written {
+		applied[task.ID] = true
+	}
+
+	for _, item := range pending {
+		if _, ok := failures[item]; !ok && item.result.Op != "create" && !applied[item.task.ID] {
+			failures[item] = fiber.NewError(fiber.StatusNotFound, "Task not found")
+		}
+	}
+
+	return nil
+}
+
+// transactionsSupported func to tell whether the server can run transactions,
+// which needs a replica set or a sharded cluster.
+func transactionsSupported(ctx context.Context, db *mongo.Database) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// withTransaction func to run the writes of fn in a transaction when the
+// server can run one. Without a replica set they run one after the other, fn
+// orders them so a failure half way leaves no reference to something gone.
+func withTransaction(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	supported, err := transactionsSupported(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return fn(ctx)
+	}
+
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	return session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return fn(sc)
+	})
+}
+
+// applyBatchEffects func to do what the single task endpoints do after their
+// write for every item of the batch that went through.
+func applyBatchEffects(ctx context.Context, db *mongo.Database, user *models.User, items []*batchItem, purgeAt time.Time) {
+	updated := []models.Task{}
+	deleted := []models.Task{}
+
+	for _, item := range items {
+		if item.failed() {
+			continue
+		}
+
+		task := item.task
+		switch item.result.Op {
+		case "create":
+			if err := recordRevision(ctx, db, user.ID, task.ID, models.RevisionCreate, nil, utils.TaskStateOf(task), nil); err != nil {
+				log.Printf("Recording history of task %s failed: %v\n", task.ID.Hex(), err)
+			}
+			publishTaskEvent(db, models.EventTaskCreated, user.ID, task, nil)
+
+		case "update":
+			updated = append(updated, *task)
+
+			if err := recordRevision(ctx, db, user.ID, task.ID, models.RevisionUpdate, utils.TaskStateOf(task), updatedTaskState(task, item.update), nil); err != nil {
+				log.Printf("Recording history of task %s failed: %v\n", task.ID.Hex(), err)
+			}
+
+			if completing, _ := item.update["completed"].(bool); completing {
+				if err := cancelTaskReminders(ctx, db, task.ID); err != nil {
+					log.Printf("Cancelling reminders of task %s failed: %v\n", task.ID.Hex(), err)
+				}
+				if !task.Completed {
+					if _, err := createNextOccurrence(ctx, db, user.ID, task.ID); err != nil {
+						log.Printf("Creating the next occurrence of task %s failed: %v\n", task.ID.Hex(), err)
+					}
+				}
+			} else {
+				dueAt := utils.TaskDateAfterUpdate(item.update, "due_at", task.DueAt)
+				if _, ok := item.update["due_at"]; ok {
+					if err := rescheduleTaskReminders(ctx, db, task.ID, dueAt); err != nil {
+						log.Printf("Rescheduling reminders of task %s failed: %v\n", task.ID.Hex(), err)
+					}
+				}
+				if reopening, ok := item.update["completed"].(bool); ok && !reopening && task.Completed {
+					if err := resumeTaskReminders(ctx, db, task.ID, dueAt); err != nil {
+						log.Printf("Resuming reminders of task %s failed: %v\n", task.ID.Hex(), err)
+					}
+				}
+			}
+
+		case "delete":
+			deleted = append(deleted, *task)
+		}
+	}
+
+	publishTaskChanges(ctx, db, models.EventTaskUpdated, user.ID, updated)
+
+	if len(deleted) == 0 {
+		return
+	}
+
+	deletedIDs := taskIDs(deleted)
+
+	// Each task is deleted on its own and restored on its own
+	for _, id := range deletedIDs {
+		if err := detachTrashedTasks(ctx, db, id, []primitive.ObjectID{id}); err != nil {
+			log.Printf("Removing dependencies and reminders of task %s failed: %v\n", id.Hex(), err)
+		}
+	}
+
+	if err := recordRevisions(ctx, db, user.ID, models.RevisionDelete, deleted, func(*models.TaskState) *models.TaskState {
+		return nil
+	}); err != nil {
+		log.Printf("Recording history of deleted tasks failed: %v\n", err)
+	}
+
+	publishTaskChanges(ctx, db, models.EventTaskDeleted, user.ID, deleted)
+
+	if err := setTaskDataPurge(ctx, db, &purgeAt, deletedIDs...); err != nil {
+		log.Printf("Scheduling the purge of the data of deleted tasks failed: %v\n", err)
+	}
+}
+
+// updatedTaskState func to work out the tracked fields of a task once a parsed
+// update is applied.
+func updatedTaskState(task *models.Task, update map[string]interface{}) *models.TaskState {
+	state := utils.TaskStateOf(task)
+	if title, ok := update["title"].(string); ok {
+		state.Title = title
+	}
+	if completed, ok := update["completed"].(bool); ok {
+		state.Completed = completed
+	}
+	if metadata, ok := update["metadata"]; ok {
+		state.Metadata, _ = metadata.(map[string]interface{})
+	}
+
+	return state
+}
+
+func skipBatch(items []*batchItem, message string) {
+	for _, item := range items {
+		if !item.failed() {
+			item.fail(fiber.StatusFailedDependency, message)
+		}
+	}
+}
+
+func countFailed(items []*batchItem) int {
+	failed := 0
+	for _, item := range items {
+		if item.failed() {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+func batchResults(items []*batchItem) []models.BatchResult {
+	results := make([]models.BatchResult, 0, len(items))
+	for _, item := range items {
+		results = append(results, item.result)
+	}
+
+	return results
+}
@@ -0,0 +1,745 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+// WebDAV methods routed besides the ones Fiber knows.
+const (
+	MethodPropfind = "PROPFIND"
+	MethodReport   = "REPORT"
+)
+
+// Every user is a principal at /dav/ with a calendar collection for each of
+// their projects under /dav/calendars/, and a VTODO resource for each task.
+const (
+	davPrincipalPath = "/dav/"
+	davCalendarsPath = "/dav/calendars/"
+	davCalendarType  = "text/calendar; charset=utf-8"
+	davXMLType       = "application/xml; charset=utf-8"
+	davTodoExtension = ".ics"
+)
+
+var (
+	davCalendarData     = xml.Name{Space: utils.NamespaceCalDAV, Local: "calendar-data"}
+	davCalendarQuery    = xml.Name{Space: utils.NamespaceCalDAV, Local: "calendar-query"}
+	davCalendarMultiget = xml.Name{Space: utils.NamespaceCalDAV, Local: "calendar-multiget"}
+)
+
+// DavOptions func to advertise CalDAV to clients discovering the server.
+func DavOptions(c *fiber.Ctx) error {
+	c.Set("DAV", "1, 3, calendar-access")
+	c.Set(fiber.HeaderAllow, "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// DavPrincipal func to answer a PROPFIND on the principal of the user, which
+// points clients to their calendars.
+func DavPrincipal(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	request, err := utils.ParseDavRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	props := []utils.DavProp{
+		davProp(utils.NamespaceDAV, "resourcetype", "<D:collection/><D:principal/>"),
+		davProp(utils.NamespaceDAV, "displayname", utils.DavText(strings.TrimSpace(user.FirstName+" "+user.LastName))),
+		davProp(utils.NamespaceDAV, "current-user-principal", utils.DavHref(davPrincipalPath)),
+		davProp(utils.NamespaceDAV, "principal-URL", utils.DavHref(davPrincipalPath)),
+		davProp(utils.NamespaceCalDAV, "calendar-home-set", utils.DavHref(davCalendarsPath)),
+		davProp(utils.NamespaceCalDAV, "calendar-user-address-set", utils.DavHref("mailto:"+user.Email)),
+	}
+
+	return davMultistatus(c, []utils.DavResponse{davResponse(request, davPrincipalPath, props)})
+}
+
+// DavCalendars func to answer a PROPFIND on the calendar home, listing the
+// active projects of the user and the ones shared with them.
+func DavCalendars(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	request, err := utils.ParseDavRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	props := []utils.DavProp{
+		davProp(utils.NamespaceDAV, "resourcetype", "<D:collection/>"),
+		davProp(utils.NamespaceDAV, "displayname", "Calendars"),
+		davProp(utils.NamespaceDAV, "current-user-principal", utils.DavHref(davPrincipalPath)),
+	}
+	responses := []utils.DavResponse{davResponse(request, davCalendarsPath, props)}
+
+	if c.Get("Depth") != "0" {
+		db := c.Locals("db").(*mongo.Database)
+
+		projects, err := davProjects(c.Context(), db, user.ID)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		tags, err := calendarTags(c.Context(), db, projectIDs(projects))
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		for i := range projects {
+			responses = append(responses, davResponse(request, calendarHref(&projects[i]), calendarProps(&projects[i], user.ID, tags[projects[i].ID])))
+		}
+	}
+
+	return davMultistatus(c, responses)
+}
+
+// DavCalendar func to answer a PROPFIND on the calendar of a project, with
+// Depth: 1 listing its tasks.
+func DavCalendar(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	project, err := davProject(c, models.RoleViewer)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	request, err := utils.ParseDavRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	tags, err := calendarTags(c.Context(), db, []primitive.ObjectID{project.ID})
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	href := calendarHref(project)
+	responses := []utils.DavResponse{davResponse(request, href, calendarProps(project, user.ID, tags[project.ID]))}
+
+	if c.Get("Depth") != "0" {
+		tasks, err := calendarTodos(c.Context(), db, project.ID, utils.TodoFilter{})
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		for i := range tasks {
+			props, err := todoProps(&tasks[i], request)
+			if err != nil {
+				return c.SendStatus(fiber.StatusInternalServerError)
+			}
+			responses = append(responses, davResponse(request, href+todoResourceName(&tasks[i]), props))
+		}
+	}
+
+	return davMultistatus(c, responses)
+}
+
+// DavCalendarReport func to answer a calendar-query or calendar-multiget
+// REPORT on the calendar of a project.
+func DavCalendarReport(c *fiber.Ctx) error {
+	project, err := davProject(c, models.RoleViewer)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	request, err := utils.ParseDavRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	href := calendarHref(project)
+	responses := []utils.DavResponse{}
+
+	switch request.Type {
+	case davCalendarQuery:
+		tasks, err := calendarTodos(c.Context(), db, project.ID, request.Filter)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		for i := range tasks {
+			props, err := todoProps(&tasks[i], request)
+			if err != nil {
+				return c.SendStatus(fiber.StatusInternalServerError)
+			}
+			responses = append(responses, davResponse(request, href+todoResourceName(&tasks[i]), props))
+		}
+	case davCalendarMultiget:
+		names := make([]string, 0, len(request.Hrefs))
+		for _, todoHref := range request.Hrefs {
+			names = append(names, hrefResourceName(todoHref, href))
+		}
+
+		tasks, err := davTodos(c.Context(), db, project.ID, names)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		for i, todoHref := range request.Hrefs {
+			task, ok := tasks[names[i]]
+			if !ok {
+				responses = append(responses, utils.DavResponse{Href: todoHref, Status: fiber.StatusNotFound})
+				continue
+			}
+
+			props, err := todoProps(task, request)
+			if err != nil {
+				return c.SendStatus(fiber.StatusInternalServerError)
+			}
+			responses = append(responses, davResponse(request, todoHref, props))
+		}
+	default:
+		return davError(c, fiber.StatusForbidden, xml.Name{Space: utils.NamespaceDAV, Local: "supported-report"}, "")
+	}
+
+	return davMultistatus(c, responses)
+}
+
+// DavTodoProps func to answer a PROPFIND on the resource of a task.
+func DavTodoProps(c *fiber.Ctx) error {
+	project, err := davProject(c, models.RoleViewer)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	request, err := utils.ParseDavRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	task, err := davTodo(c.Context(), db, project.ID, c.Params("name"))
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	props, err := todoProps(task, request)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return davMultistatus(c, []utils.DavResponse{davResponse(request, calendarHref(project)+todoResourceName(task), props)})
+}
+
+// DavGetTodo func to serve a task as a calendar object resource.
+func DavGetTodo(c *fiber.Ctx) error {
+	project, err := davProject(c, models.RoleViewer)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	task, err := davTodo(c.Context(), db, project.ID, c.Params("name"))
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := utils.WriteTodo(&buffer, task); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderETag, utils.TaskETag(task.Version))
+	c.Set(fiber.HeaderContentType, davCalendarType)
+
+	return c.Status(fiber.StatusOK).Send(buffer.Bytes())
+}
+
+// DavPutTodo func to create or update a task from a VTODO. Writes go through
+// the same checks and side effects as the task API. The ETag of the stored
+// task is returned, clients read it back to see what it kept.
+func DavPutTodo(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	project, err := davProject(c, models.RoleEditor)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	name := c.Params("name")
+	if !strings.HasSuffix(name, davTodoExtension) {
+		return c.Status(fiber.StatusBadRequest).SendString("Resource names must end in " + davTodoExtension)
+	}
+
+	todo, err := utils.ParseTodo(c.Body())
+	if err == utils.ErrNoTodo {
+		return davError(c, fiber.StatusForbidden, xml.Name{Space: utils.NamespaceCalDAV, Local: "supported-calendar-component"}, "")
+	}
+	if err != nil {
+		return davError(c, fiber.StatusBadRequest, xml.Name{Space: utils.NamespaceCalDAV, Local: "valid-calendar-data"}, utils.DavText(err.Error()))
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+
+	task, err := davTodo(c.Context(), db, project.ID, name)
+	if err == mongo.ErrNoDocuments {
+		if ifMatch != "" {
+			return c.SendStatus(fiber.StatusPreconditionFailed)
+		}
+		return createDavTodo(c, user, project, name, todo)
+	}
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	if c.Get(fiber.HeaderIfNoneMatch) == "*" || !utils.IfMatch(ifMatch, task.Version) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+
+	if todo.UID != utils.TodoUID(task) {
+		return davError(c, fiber.StatusConflict, xml.Name{Space: utils.NamespaceCalDAV, Local: "no-uid-conflict"}, utils.DavHref(calendarHref(project)+name))
+	}
+
+	update, err := todoUpdate(task, todo)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if len(update) == 0 {
+		c.Set(fiber.HeaderETag, utils.TaskETag(task.Version))
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	update["updated_at"] = time.Now().Unix()
+
+	if err := prepareTaskUpdate(c.Context(), db, task, update); err != nil {
+		return davTaskError(c, err)
+	}
+
+	result, err := applyTaskUpdate(c.Context(), db, user, task, ifMatch, update, false, false)
+	if err != nil {
+		return davTaskError(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, utils.TaskETag(result.task.Version))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DavDeleteTodo func to move the task of a resource to the trash, handing its
+// subtasks to its parent.
+func DavDeleteTodo(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	project, err := davProject(c, models.RoleEditor)
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	task, err := davTodo(c.Context(), db, project.ID, c.Params("name"))
+	if err != nil {
+		return davAccessError(c, err)
+	}
+
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if !utils.IfMatch(ifMatch, task.Version) {
+		return c.SendStatus(fiber.StatusPreconditionFailed)
+	}
+
+	if _, err := trashTask(c.Context(), db, user, task, ifMatch, false); err != nil {
+		return davTaskError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func createDavTodo(c *fiber.Ctx, user *models.User, project *models.Project, name string, todo *utils.ICalTodo) error {
+	db := c.Locals("db").(*mongo.Database)
+
+	// A UID names one resource of a calendar
+	existing := new(models.Task)
+	uidFilter := bson.A{bson.M{"ical_uid": todo.UID}}
+	if id, ok := utils.TodoTaskID(todo.UID); ok {
+		uidFilter = append(uidFilter, bson.M{"_id": id, "ical_uid": bson.M{"$exists": false}})
+	}
+	err := db.Collection(os.Getenv("TASKS_COLLECTION")).FindOne(c.Context(), bson.M{"project_id": project.ID, "deleted_at": bson.M{"$exists": false}, "$or": uidFilter}).Decode(existing)
+	if err == nil {
+		return davError(c, fiber.StatusConflict, xml.Name{Space: utils.NamespaceCalDAV, Local: "no-uid-conflict"}, utils.DavHref(calendarHref(project)+todoResourceName(existing)))
+	}
+	if err != mongo.ErrNoDocuments {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	createTask := &models.CreateTask{
+		Title:      todo.Summary,
+		Completed:  todo.Completed,
+		StartAt:    todo.StartAt,
+		DueAt:      todo.DueAt,
+		ProjectID:  project.ID.Hex(),
+		Recurrence: todo.Recurrence,
+	}
+	if err := validator.New().Struct(createTask); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	// iCalendar has no place for custom fields, those without a default stay
+	// unset until the task is edited through the API
+	task, err := newTask(c.Context(), db, user, createTask, metadataDefaults)
+	if err != nil {
+		return davTaskError(c, err)
+	}
+	task.DavName = name
+	task.ICalUID = todo.UID
+	task.StartForm = todo.StartForm
+	task.DueForm = todo.DueForm
+	task.TimeZone = todo.TimeZone
+
+	if err := insertTask(c.Context(), db, user.ID, task); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderETag, utils.TaskETag(task.Version))
+
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// todoUpdate func to turn a VTODO into the changes it makes to a task, in the
+// form the task API parses updates into.
+func todoUpdate(task *models.Task, todo *utils.ICalTodo) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if todo.Summary != task.Title {
+		if todo.Summary == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "VTODO has no SUMMARY")
+		}
+		update["title"] = todo.Summary
+	}
+	if todo.Completed != task.Completed {
+		update["completed"] = todo.Completed
+	}
+
+	for _, date := range []struct {
+		key, formKey      string
+		value, current    *int64
+		form, currentForm string
+	}{
+		{"start_at", "start_form", todo.StartAt, task.StartAt, todo.StartForm, task.StartForm},
+		{"due_at", "due_form", todo.DueAt, task.DueAt, todo.DueForm, task.DueForm},
+	} {
+		if date.value == nil && date.current != nil {
+			update[date.key] = nil
+		}
+		if date.value != nil && (date.current == nil || *date.value != *date.current) {
+			update[date.key] = *date.value
+		}
+		// A changed date takes the form it was sent in
+		if _, ok := update[date.key]; ok || date.form != date.currentForm {
+			update[date.formKey] = date.form
+		}
+	}
+	if todo.TimeZone != "" && todo.TimeZone != task.TimeZone {
+		update["time_zone"] = todo.TimeZone
+	}
+
+	if todo.Recurrence != task.Recurrence {
+		update["recurrence"] = nil
+		if todo.Recurrence != "" {
+			if _, err := utils.ParseRRule(todo.Recurrence); err != nil {
+				return nil, err
+			}
+			update["recurrence"] = todo.Recurrence
+		}
+	}
+
+	return update, nil
+}
+
+// davProjects func to list the projects the user has calendars for, the Inbox
+// first.
+func davProjects(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) ([]models.Project, error) {
+	if _, err := userInbox(ctx, db, userID); err != nil {
+		return nil, err
+	}
+
+	projects := []models.Project{}
+
+	filter := bson.M{"$or": bson.A{bson.M{"user_id": userID}, bson.M{"members.user_id": userID}}, "archived": false}
+	opts := options.Find().SetSort(bson.D{{Key: "inbox", Value: -1}, {Key: "name", Value: 1}})
+	cursor, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// davProject func to load the project of the calendar in the path.
+func davProject(c *fiber.Ctx, required string) (*models.Project, error) {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("project"))
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	return utils.ProjectAccess(c.Context(), db, user.ID, id, required)
+}
+
+// calendarTags func to tag the calendars of projects with a hash of the
+// versions of their tasks, so clients only read them again after a change.
+func calendarTags(ctx context.Context, db *mongo.Database, ids []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	var tasks []models.Task
+
+	opts := options.Find().
+		SetProjection(bson.M{"project_id": 1, "version": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, bson.M{"project_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	hashes := map[primitive.ObjectID][]byte{}
+	for _, task := range tasks {
+		hashes[task.ProjectId] = append(hashes[task.ProjectId], []byte(task.ID.Hex()+utils.TaskETag(task.Version))...)
+	}
+
+	tags := make(map[primitive.ObjectID]string, len(ids))
+	for _, id := range ids {
+		hash := sha256.Sum256(hashes[id])
+		tags[id] = `"` + hex.EncodeToString(hash[:16]) + `"`
+	}
+
+	return tags, nil
+}
+
+// calendarTodos func to load the tasks of a project a calendar-query matches.
+func calendarTodos(ctx context.Context, db *mongo.Database, projectID primitive.ObjectID, filter utils.TodoFilter) ([]models.Task, error) {
+	tasks := []models.Task{}
+	if filter.NoTodos {
+		return tasks, nil
+	}
+
+	query := bson.M{"project_id": projectID, "deleted_at": bson.M{"$exists": false}}
+	if filter.Completed != nil {
+		query["completed"] = *filter.Completed
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// davTodos func to load the tasks of a project by resource name. Tasks created
+// over CalDAV have the name their client gave them, the others their ID.
+func davTodos(ctx context.Context, db *mongo.Database, projectID primitive.ObjectID, names []string) (map[string]*models.Task, error) {
+	ids := bson.A{}
+	for _, name := range names {
+		if id, err := primitive.ObjectIDFromHex(strings.TrimSuffix(name, davTodoExtension)); err == nil && strings.HasSuffix(name, davTodoExtension) {
+			ids = append(ids, id)
+		}
+	}
+
+	var tasks []models.Task
+
+	filter := bson.M{
+		"project_id": projectID,
+		"deleted_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"dav_name": bson.M{"$in": names}},
+			bson.M{"_id": bson.M{"$in": ids}, "dav_name": bson.M{"$exists": false}},
+		},
+	}
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*models.Task, len(tasks))
+	for i := range tasks {
+		byName[todoResourceName(&tasks[i])] = &tasks[i]
+	}
+
+	return byName, nil
+}
+
+func davTodo(ctx context.Context, db *mongo.Database, projectID primitive.ObjectID, name string) (*models.Task, error) {
+	tasks, err := davTodos(ctx, db, projectID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	task, ok := tasks[name]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return task, nil
+}
+
+func calendarProps(project *models.Project, userID primitive.ObjectID, tag string) []utils.DavProp {
+	privileges := "<D:privilege><D:read/></D:privilege>"
+	if utils.RoleAllows(utils.ProjectRole(project, userID), models.RoleEditor) {
+		privileges += "<D:privilege><D:write/></D:privilege><D:privilege><D:write-content/></D:privilege><D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"
+	}
+
+	props := []utils.DavProp{
+		davProp(utils.NamespaceDAV, "resourcetype", "<D:collection/><C:calendar/>"),
+		davProp(utils.NamespaceDAV, "displayname", utils.DavText(project.Name)),
+		davProp(utils.NamespaceDAV, "current-user-principal", utils.DavHref(davPrincipalPath)),
+		davProp(utils.NamespaceDAV, "current-user-privilege-set", privileges),
+		davProp(utils.NamespaceDAV, "supported-report-set", "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report><D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"),
+		davProp(utils.NamespaceCalDAV, "supported-calendar-component-set", `<C:comp name="VTODO"/>`),
+		davProp(utils.NamespaceCalDAV, "supported-calendar-data", `<C:calendar-data content-type="text/calendar" version="2.0"/>`),
+		davProp(utils.NamespaceCalendarServer, "getctag", utils.DavText(tag)),
+	}
+	if project.Description != "" {
+		props = append(props, davProp(utils.NamespaceCalDAV, "calendar-description", utils.DavText(project.Description)))
+	}
+	if project.Color != "" {
+		props = append(props, davProp(utils.NamespaceAppleICal, "calendar-color", utils.DavText(project.Color)))
+	}
+
+	return props
+}
+
+// todoProps func to list the properties of the resource of a task. Its
+// calendar data is only included when asked for by name.
+func todoProps(task *models.Task, request *utils.DavRequest) ([]utils.DavProp, error) {
+	props := []utils.DavProp{
+		davProp(utils.NamespaceDAV, "resourcetype", ""),
+		davProp(utils.NamespaceDAV, "getetag", utils.DavText(utils.TaskETag(task.Version))),
+		davProp(utils.NamespaceDAV, "getcontenttype", "text/calendar; charset=utf-8; component=VTODO"),
+		davProp(utils.NamespaceDAV, "getlastmodified", time.Unix(task.UpdatedAt, 0).UTC().Format(http.TimeFormat)),
+	}
+
+	for _, name := range request.Props {
+		if name == davCalendarData {
+			var buffer bytes.Buffer
+			if err := utils.WriteTodo(&buffer, task); err != nil {
+				return nil, err
+			}
+			props = append(props, utils.DavProp{Name: davCalendarData, Value: utils.DavText(buffer.String())})
+		}
+	}
+
+	return props, nil
+}
+
+func todoResourceName(task *models.Task) string {
+	if task.DavName != "" {
+		return task.DavName
+	}
+
+	return task.ID.Hex() + davTodoExtension
+}
+
+func calendarHref(project *models.Project) string {
+	return davCalendarsPath + project.ID.Hex() + "/"
+}
+
+// hrefResourceName func to return the name of a resource in a calendar from
+// its href, which clients may send as a full URL.
+func hrefResourceName(href, calendar string) string {
+	if parsed, err := url.Parse(href); err == nil {
+		href = parsed.EscapedPath()
+	}
+
+	name := strings.TrimPrefix(href, calendar)
+	if name == href || strings.Contains(name, "/") {
+		return ""
+	}
+
+	return name
+}
+
+func davProp(space, local, value string) utils.DavProp {
+	return utils.DavProp{Name: xml.Name{Space: space, Local: local}, Value: value}
+}
+
+func davResponse(request *utils.DavRequest, href string, props []utils.DavProp) utils.DavResponse {
+	found, missing := utils.DavProps(request, props)
+
+	return utils.DavResponse{Href: href, Props: found, Missing: missing}
+}
+
+func davMultistatus(c *fiber.Ctx, responses []utils.DavResponse) error {
+	var buffer bytes.Buffer
+	if err := utils.WriteMultistatus(&buffer, responses); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderContentType, davXMLType)
+
+	return c.Status(fiber.StatusMultiStatus).Send(buffer.Bytes())
+}
+
+// davError func to answer with the precondition or postcondition that failed.
+func davError(c *fiber.Ctx, status int, condition xml.Name, value string) error {
+	c.Set(fiber.HeaderContentType, davXMLType)
+
+	return c.Status(status).SendString(utils.DavErrorBody(condition, value))
+}
+
+func davAccessError(c *fiber.Ctx, err error) error {
+	switch err {
+	case mongo.ErrNoDocuments:
+		return c.SendStatus(fiber.StatusNotFound)
+	case utils.ErrForbidden:
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	return c.SendStatus(fiber.StatusInternalServerError)
+}
+
+// davTaskError func to answer an error from writing a task the way
+// taskRequestError does, in plain text.
+func davTaskError(c *fiber.Ctx, err error) error {
+	switch e := err.(type) {
+	case utils.FieldErrors:
+		return c.Status(fiber.StatusBadRequest).SendString(e.Error())
+	case *fiber.Error:
+		return c.Status(e.Code).SendString(e.Message)
+	case *blockedTaskError:
+		return c.Status(fiber.StatusConflict).SendString(e.Error())
+	}
+
+	return c.SendStatus(fiber.StatusInternalServerError)
+}
@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func CreateComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	validate := validator.New()
+
+	createComment := new(models.CreateComment)
+	if err := c.BodyParser(&createComment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createComment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	mentions, err := commentMentions(c.Context(), db, task, createComment.Body)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	comment := new(models.Comment)
+	comment.TaskId = task.ID
+	comment.UserId = user.ID
+	comment.Body = createComment.Body
+	comment.Mentions = mentions
+	comment.CreatedAt = time.Now().Unix()
+
+	res, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).InsertOne(c.Context(), comment)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	comment.ID = res.InsertedID.(primitive.ObjectID)
+	if err := notifyMentions(c.Context(), db, comment, mentions); err != nil {
+		log.Printf("Notifying mentions of comment %s failed: %v\n", comment.ID.Hex(), err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Comment created successfully",
+		"comment": res.InsertedID,
+	})
+}
+
+func GetComments(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	var comments []models.Comment
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).Find(c.Context(), fiber.Map{"task_id": task.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &comments); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	commentsResponse := make([]models.GetComment, 0, len(comments))
+	for _, comment := range comments {
+		commentsResponse = append(commentsResponse, commentResponse(&comment))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    false,
+		"comments": commentsResponse,
+	})
+}
+
+func UpdateComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	validate := validator.New()
+
+	commentID, err := primitive.ObjectIDFromHex(c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid comment ID",
+		})
+	}
+
+	updateComment := new(models.UpdateComment)
+	if err := c.BodyParser(&updateComment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateComment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	mentions, err := commentMentions(c.Context(), db, task, updateComment.Body)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Only the author can edit a comment, and deleted comments stay deleted
+	comment := new(models.Comment)
+	filter := fiber.Map{"_id": commentID, "task_id": task.ID, "user_id": user.ID, "deleted_at": nil}
+	update := bson.M{"$set": bson.M{"body": updateComment.Body, "mentions": mentions, "edited_at": time.Now().Unix()}}
+	if err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).FindOneAndUpdate(c.Context(), filter, update).Decode(comment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Comment not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Users that were already mentioned have been notified before
+	added := []primitive.ObjectID{}
+	for _, id := range mentions {
+		if !containsID(comment.Mentions, id) {
+			added = append(added, id)
+		}
+	}
+
+	if err := notifyMentions(c.Context(), db, comment, added); err != nil {
+		log.Printf("Notifying mentions of comment %s failed: %v\n", comment.ID.Hex(), err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Comment updated successfully",
+	})
+}
+
+func DeleteComment(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	commentID, err := primitive.ObjectIDFromHex(c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid comment ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).UpdateOne(c.Context(),
+		fiber.Map{"_id": commentID, "task_id": task.ID, "user_id": user.ID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now().Unix()}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Comment not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Comment deleted successfully",
+	})
+}
+
+// commentMentions func to resolve the @email mentions of a comment into the
+// users that exist and can access the task.
+func commentMentions(ctx context.Context, db *mongo.Database, task *models.Task, body string) ([]primitive.ObjectID, error) {
+	emails := utils.ParseMentions(body)
+	if len(emails) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+
+	var users []models.User
+
+	// Emails are matched regardless of case
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2})
+
+	cursor, err := db.Collection(os.Getenv("USER_COLLECTION")).Find(ctx, bson.M{"email": bson.M{"$in": emails}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	mentions := []primitive.ObjectID{}
+	for _, user := range users {
+		_, err := utils.TaskAccess(ctx, db, user.ID, task.ID, models.RoleViewer)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, user.ID)
+	}
+
+	return mentions, nil
+}
+
+// notifyMentions func to create a mention notification for every mentioned
+// user except the author.
+func notifyMentions(ctx context.Context, db *mongo.Database, comment *models.Comment, mentions []primitive.ObjectID) error {
+	timestamp := time.Now().Unix()
+
+	notifications := []interface{}{}
+	for _, id := range mentions {
+		if id == comment.UserId {
+			continue
+		}
+
+		notifications = append(notifications, models.Notification{
+			UserId:    id,
+			Type:      models.NotificationMention,
+			ActorId:   comment.UserId,
+			TaskId:    comment.TaskId,
+			CommentId: &comment.ID,
+			CreatedAt: timestamp,
+		})
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	_, err := db.Collection(os.Getenv("NOTIFICATIONS_COLLECTION")).InsertMany(ctx, notifications)
+
+	return err
+}
+
+// fillCommentCounts func to set the number of comments that are not deleted on
+// the responses of the tasks.
+func fillCommentCounts(ctx context.Context, db *mongo.Database, tasks []models.Task, responses []models.GetTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"task_id": bson.M{"$in": taskIDs(tasks)}, "deleted_at": nil}}},
+		{{Key: "$group", Value: bson.M{"_id": "$task_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int64              `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	counts := map[primitive.ObjectID]int64{}
+	for _, result := range results {
+		counts[result.ID] = result.Count
+	}
+
+	for i, task := range tasks {
+		responses[i].Comments = counts[task.ID]
+	}
+
+	return nil
+}
+
+// deleteTaskComments func to remove the comments of deleted tasks.
+func deleteTaskComments(ctx context.Context, db *mongo.Database, taskIDs ...primitive.ObjectID) error {
+	_, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).DeleteMany(ctx, bson.M{"task_id": bson.M{"$in": taskIDs}})
+
+	return err
+}
+
+func commentResponse(comment *models.Comment) models.GetComment {
+	response := models.GetComment{
+		ID:        comment.ID.Hex(),
+		TaskID:    comment.TaskId.Hex(),
+		AuthorID:  comment.UserId.Hex(),
+		Body:      comment.Body,
+		Mentions:  []string{},
+		Deleted:   comment.DeletedAt != nil,
+		CreatedAt: comment.CreatedAt,
+		EditedAt:  comment.EditedAt,
+	}
+
+	if response.Deleted {
+		response.Body = ""
+		return response
+	}
+
+	for _, id := range comment.Mentions {
+		response.Mentions = append(response.Mentions, id.Hex())
+	}
+
+	return response
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+
+	return false
+}
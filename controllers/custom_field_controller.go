@@ -0,0 +1,400 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func CreateCustomField(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	createField := new(models.CreateCustomField)
+	if err := c.BodyParser(&createField); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createField); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	field := new(models.CustomField)
+	field.UserId = user.ID
+	field.Name = createField.Name
+	field.Type = createField.Type
+	field.Required = createField.Required
+	field.Default = createField.Default
+	field.Options = createField.Options
+	field.CreatedAt = timestamp
+	field.UpdatedAt = timestamp
+
+	if err := utils.CheckCustomField(field); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("FIELDS_COLLECTION")).InsertOne(c.Context(), field)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Custom field already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Tasks may already use the name as a free form key
+	converted, unconverted, err := convertFieldValues(c.Context(), db, field)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":       false,
+		"message":     "Custom field created successfully",
+		"field":       res.InsertedID,
+		"converted":   converted,
+		"unconverted": unconverted,
+	})
+}
+
+func GetCustomFields(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+	fields, err := userCustomFields(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	fieldsResponse := make([]models.GetCustomField, 0, len(fields))
+	for _, field := range fields {
+		fieldsResponse = append(fieldsResponse, customFieldResponse(&field))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":  false,
+		"fields": fieldsResponse,
+	})
+}
+
+func GetCustomField(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid custom field ID",
+		})
+	}
+
+	field := new(models.CustomField)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("FIELDS_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}).Decode(&field); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Custom field not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"field": customFieldResponse(field),
+	})
+}
+
+// UpdateCustomField func to change whether a field is required, its default
+// and its options. Tasks keep the values they hold, values that no longer fit
+// are only checked once an update changes them.
+func UpdateCustomField(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid custom field ID",
+		})
+	}
+
+	updateField := new(models.UpdateCustomField)
+	if err := c.BodyParser(&updateField); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateField); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	field := new(models.CustomField)
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("FIELDS_COLLECTION"))
+	if err := collection.FindOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}).Decode(&field); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Custom field not found",
+		})
+	}
+
+	if updateField.Required != nil {
+		field.Required = *updateField.Required
+	}
+	if updateField.Options != nil {
+		field.Options = updateField.Options
+	}
+	if updateField.Default != nil {
+		field.Default = nil
+		if err := json.Unmarshal(updateField.Default, &field.Default); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+	}
+
+	// The default has to be one of the options that are left
+	if err := utils.CheckCustomField(field); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	update := bson.M{
+		"$set": bson.M{"required": field.Required, "options": field.Options, "updated_at": time.Now().Unix()},
+	}
+	if field.Default != nil {
+		update["$set"].(bson.M)["default"] = field.Default
+	} else {
+		update["$unset"] = bson.M{"default": ""}
+	}
+
+	res, err := collection.UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Custom field not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Custom field updated successfully",
+	})
+}
+
+// DeleteCustomField func to remove the definition of a field. The values tasks
+// hold for it stay as free form metadata.
+func DeleteCustomField(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid custom field ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("FIELDS_COLLECTION")).DeleteOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Custom field not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Custom field deleted successfully",
+	})
+}
+
+// convertFieldValues func to turn the values tasks hold for the key of a new
+// custom field into values of its type, so typed filters and sorting see
+// them. It returns how many were converted and how many could not be, those
+// are kept as they are until the task changes them.
+func convertFieldValues(ctx context.Context, db *mongo.Database, field *models.CustomField) (int, int, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+	key := "metadata." + field.Name
+
+	var tasks []models.Task
+
+	opts := options.Find().SetProjection(bson.M{"metadata": 1})
+	cursor, err := collection.Find(ctx, bson.M{"user_id": field.UserId, key: bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return 0, 0, err
+	}
+
+	writes := []mongo.WriteModel{}
+	unconverted := 0
+	for _, task := range tasks {
+		value := task.Metadata[field.Name]
+		if _, err := utils.FieldValue(field, value); err == nil {
+			continue
+		}
+
+		typed, ok := utils.CoerceFieldValue(field, value)
+		if !ok {
+			unconverted++
+			continue
+		}
+
+		task.Metadata[field.Name] = typed
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": task.ID, key: value}).
+			SetUpdate(bson.M{"$set": bson.M{key: typed, "search_text": utils.TaskSearchText(task.Metadata)}, "$inc": bson.M{"version": 1}}))
+	}
+
+	if len(writes) == 0 {
+		return 0, unconverted, nil
+	}
+
+	res, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(res.ModifiedCount), unconverted, nil
+}
+
+// userCustomFields func to load the custom fields of a user by name.
+func userCustomFields(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := db.Collection(os.Getenv("FIELDS_COLLECTION")).Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// metadataCheck is how checkTaskMetadata treats the custom fields missing
+// from the metadata of a task.
+type metadataCheck int
+
+const (
+	// metadataUpdate only checks the keys the update changes against the
+	// previous metadata, and keeps required fields that were set
+	metadataUpdate metadataCheck = iota
+	// metadataCreate fills in defaults and requires the required fields
+	metadataCreate
+	// metadataDefaults only fills in defaults, for clients like CalDAV that
+	// have no way to send custom fields
+	metadataDefaults
+)
+
+// checkTaskMetadata func to check the metadata of a task against the custom
+// fields of its owner, so every member of a shared project writes the same
+// schema. Previous is the metadata the task has before an update. Problems are
+// returned as utils.FieldErrors.
+func checkTaskMetadata(ctx context.Context, db *mongo.Database, ownerID primitive.ObjectID, metadata, previous map[string]interface{}, check metadataCheck) (map[string]interface{}, error) {
+	fields, err := userCustomFields(ctx, db, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if check != metadataUpdate {
+		metadata = utils.WithFieldDefaults(fields, metadata)
+	}
+	if check == metadataDefaults {
+		for i := range fields {
+			fields[i].Required = false
+		}
+	}
+
+	if check != metadataUpdate {
+		previous = nil
+	} else if previous == nil {
+		previous = map[string]interface{}{}
+	}
+
+	checked, err := utils.CheckTaskMetadata(fields, metadata, previous)
+	if err != nil || len(checked) == 0 {
+		return nil, err
+	}
+
+	return checked, nil
+}
+
+func customFieldResponse(field *models.CustomField) models.GetCustomField {
+	return models.GetCustomField{
+		ID:        field.ID.Hex(),
+		Name:      field.Name,
+		Type:      field.Type,
+		Required:  field.Required,
+		Default:   field.Default,
+		Options:   field.Options,
+		CreatedAt: field.CreatedAt,
+		UpdatedAt: field.UpdatedAt,
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func AddTaskDependency(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	id := task.ID
+	validate := validator.New()
+
+	addDependency := new(models.AddDependency)
+	if err := c.BodyParser(&addDependency); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(addDependency); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	blockerID, _ := primitive.ObjectIDFromHex(addDependency.BlockedBy)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	blocker, err := utils.TaskAccess(c.Context(), db, user.ID, blockerID, models.RoleViewer)
+	if err != nil || blocker.UserId != task.UserId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Blocking task not found",
+		})
+	}
+
+	// Dependencies stay within one project, everyone who sees a task sees
+	// what it waits on
+	if blocker.ProjectId != task.ProjectId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Blocking task must be in the same project",
+		})
+	}
+
+	if blockerID == id {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Dependency would create a cycle",
+		})
+	}
+
+	added, err := addTaskBlocker(c.Context(), db, user.ID, task, blockerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if !added {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Dependency would create a cycle",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Dependency added successfully",
+	})
+}
+
+func RemoveTaskDependency(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	blockerID, err := primitive.ObjectIDFromHex(c.Params("blockerId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid blocking task ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": task.ID, "user_id": task.UserId, "blocked_by": blockerID}, bson.M{"$pull": bson.M{"blocked_by": blockerID}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Dependency not found",
+		})
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, []models.Task{*task})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Dependency removed successfully",
+	})
+}
+
+// addTaskBlocker func to make a task wait on a blocker unless that closes a
+// cycle, which it does when the blocker already waits on the task. Two
+// requests can each add one half of a cycle, so a new edge is checked again
+// once it is written and taken back if a cycle showed up in between. The
+// update of the task is published whenever it was written.
+func addTaskBlocker(ctx context.Context, db *mongo.Database, actorID primitive.ObjectID, task *models.Task, blockerID primitive.ObjectID) (bool, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+	userID, taskID := task.UserId, task.ID
+
+	closesCycle := func() (bool, error) {
+		blockerDependencies, err := taskDependencies(ctx, db, userID, blockerID)
+		if err != nil {
+			return false, err
+		}
+
+		return containsTask(blockerDependencies, taskID), nil
+	}
+
+	if cycle, err := closesCycle(); err != nil || cycle {
+		return false, err
+	}
+
+	res, err := collection.UpdateOne(ctx, fiber.Map{"_id": taskID, "user_id": userID}, bson.M{"$addToSet": bson.M{"blocked_by": blockerID}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return false, err
+	}
+
+	// The edge was already there, it was checked when it was added
+	if res.ModifiedCount == 0 {
+		return true, nil
+	}
+	defer publishTaskChanges(ctx, db, models.EventTaskUpdated, actorID, []models.Task{*task})
+
+	cycle, err := closesCycle()
+	if err != nil {
+		return false, err
+	}
+	if !cycle {
+		return true, nil
+	}
+
+	if _, err := collection.UpdateOne(ctx, fiber.Map{"_id": taskID, "user_id": userID}, bson.M{"$pull": bson.M{"blocked_by": blockerID}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}}); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// taskDependencies func to load every task the given one waits on, directly or
+// through other blockers.
+func taskDependencies(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID) ([]models.Task, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": id, "user_id": userID}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":                    collection.Name(),
+			"startWith":               "$blocked_by",
+			"connectFromField":        "blocked_by",
+			"connectToField":          "_id",
+			"as":                      "dependencies",
+			"restrictSearchWithMatch": bson.M{"user_id": userID},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		Dependencies []models.Task `bson:"dependencies"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return []models.Task{}, nil
+	}
+
+	return results[0].Dependencies, nil
+}
+
+// taskReferences func to load a short description of the tasks matching filter.
+func taskReferences(ctx context.Context, db *mongo.Database, filter bson.M) ([]models.TaskReference, error) {
+	var tasks []models.Task
+
+	opts := options.Find().
+		SetProjection(bson.M{"title": 1, "completed": 1}).
+		SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	references := make([]models.TaskReference, 0, len(tasks))
+	for _, task := range tasks {
+		references = append(references, models.TaskReference{
+			ID:        task.ID.Hex(),
+			Title:     task.Title,
+			Completed: task.Completed,
+		})
+	}
+
+	return references, nil
+}
+
+// openBlockers func to list the incomplete tasks the given one waits on.
+func openBlockers(ctx context.Context, db *mongo.Database, task *models.Task) ([]models.TaskReference, error) {
+	if len(task.BlockedBy) == 0 {
+		return []models.TaskReference{}, nil
+	}
+
+	return taskReferences(ctx, db, bson.M{"_id": bson.M{"$in": task.BlockedBy}, "completed": false})
+}
+
+// unblockedTasks func to find the open tasks that were waiting on one of the
+// completed tasks and have no incomplete blocker left.
+func unblockedTasks(ctx context.Context, db *mongo.Database, completedIDs []primitive.ObjectID) ([]models.TaskReference, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"blocked_by": bson.M{"$in": completedIDs}, "completed": false, "deleted_at": bson.M{"$exists": false}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         collection.Name(),
+			"localField":   "blocked_by",
+			"foreignField": "_id",
+			"as":           "blockers",
+		}}},
+		{{Key: "$match", Value: bson.M{"blockers": bson.M{"$not": bson.M{"$elemMatch": bson.M{"completed": false}}}}}},
+		{{Key: "$project", Value: bson.M{"title": 1, "completed": 1}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	references := make([]models.TaskReference, 0, len(tasks))
+	for _, task := range tasks {
+		references = append(references, models.TaskReference{
+			ID:        task.ID.Hex(),
+			Title:     task.Title,
+			Completed: task.Completed,
+		})
+	}
+
+	return references, nil
+}
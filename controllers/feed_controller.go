@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const calendarFeedPath = "/api/v1/feed/"
+
+// GetCalendarFeedStatus func to tell whether the user has a calendar feed. The
+// URL itself can not be shown again, only a new one can be created.
+func GetCalendarFeedStatus(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	feed := fiber.Map{"active": user.CalendarFeed != nil}
+	if user.CalendarFeed != nil {
+		feed["created_at"] = user.CalendarFeed.CreatedAt
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"feed":  feed,
+	})
+}
+
+// RotateCalendarFeed func to create a new secret feed URL for the user, the
+// previous one stops working.
+func RotateCalendarFeed(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	token, tokenHash, err := utils.NewSecretToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	feed := models.CalendarFeed{TokenHash: tokenHash, CreatedAt: time.Now().Unix()}
+
+	db := c.Locals("db").(*mongo.Database)
+	if _, err := db.Collection(os.Getenv("USER_COLLECTION")).UpdateOne(c.Context(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"calendar_feed": feed}}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":      false,
+		"message":    "Calendar feed created successfully",
+		"url":        c.BaseURL() + calendarFeedPath + token + ".ics",
+		"created_at": feed.CreatedAt,
+	})
+}
+
+func RevokeCalendarFeed(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("USER_COLLECTION")).UpdateOne(c.Context(), bson.M{"_id": user.ID, "calendar_feed": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"calendar_feed": ""}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.ModifiedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Calendar feed not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Calendar feed revoked successfully",
+	})
+}
+
+// GetCalendarFeed func to serve the tasks a user can see as an iCalendar file
+// of VTODO entries. The secret in the URL stands in for the JWT, calendar apps
+// can not send one. Clients that poll get a 304 while nothing changed, before
+// any task is loaded.
+func GetCalendarFeed(c *fiber.Ctx) error {
+	user := new(models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("USER_COLLECTION")).FindOne(c.Context(), bson.M{"calendar_feed.token_hash": utils.SecretTokenHash(c.Params("token"))}).Decode(&user); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Calendar feed not found",
+		})
+	}
+
+	etag, lastModified, err := calendarFeedETag(c.Context(), db, user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Clients poll the feed, they revalidate every time
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, time.Unix(lastModified, 0).UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+
+	if calendarFeedFresh(c, etag, lastModified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	visible, err := visibleTasksFilter(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	var tasks []models.Task
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(c.Context(), visible, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	var buffer bytes.Buffer
+	if err := utils.WriteCalendar(&buffer, "Tasks of "+user.FirstName, tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+
+	return c.Status(fiber.StatusOK).Send(buffer.Bytes())
+}
+
+// calendarFeedETag func to tag the feed of a user by a summary of the tasks it
+// is built from: how many there are, the sum of their versions and the last
+// change of a task the user can access, trashing included. The last change is
+// returned too, for Last-Modified.
+func calendarFeedETag(ctx context.Context, db *mongo.Database, user *models.User) (string, int64, error) {
+	accessible, err := accessibleTasksFilter(ctx, db, user.ID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	visible := bson.M{"$eq": bson.A{bson.M{"$type": "$deleted_at"}, "missing"}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: accessible}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        nil,
+			"updated_at": bson.M{"$max": "$updated_at"},
+			"count":      bson.M{"$sum": bson.M{"$cond": bson.A{visible, 1, 0}}},
+			"versions":   bson.M{"$sum": bson.M{"$cond": bson.A{visible, bson.M{"$ifNull": bson.A{"$version", 0}}, 0}}},
+		}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var summaries []struct {
+		UpdatedAt int64 `bson:"updated_at"`
+		Count     int64 `bson:"count"`
+		Versions  int64 `bson:"versions"`
+	}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return "", 0, err
+	}
+
+	var updatedAt, count, versions int64
+	if len(summaries) > 0 {
+		updatedAt, count, versions = summaries[0].UpdatedAt, summaries[0].Count, summaries[0].Versions
+	}
+
+	// The name of the user is the name of the calendar
+	hash := sha256.Sum256([]byte(user.FirstName + "\x00" + strconv.FormatInt(count, 10) + "\x00" + strconv.FormatInt(versions, 10) + "\x00" + strconv.FormatInt(updatedAt, 10)))
+
+	return `"` + hex.EncodeToString(hash[:16]) + `"`, updatedAt, nil
+}
+
+// calendarFeedFresh func to tell whether the client already has the feed.
+// If-None-Match wins over If-Modified-Since when both are sent.
+func calendarFeedFresh(c *fiber.Ctx, etag string, lastModified int64) bool {
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	return err == nil && lastModified <= since.Unix()
+}
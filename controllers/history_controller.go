@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func GetTaskHistory(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	limit := c.QueryInt("limit", utils.DefaultPageLimit)
+	if limit < 1 || limit > utils.MaxPageLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "limit must be a number between 1 and " + strconv.Itoa(utils.MaxPageLimit),
+		})
+	}
+
+	var revisions []models.Revision
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).Find(c.Context(), fiber.Map{"task_id": task.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &revisions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	revisionsResponse := make([]models.GetRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		response := models.GetRevision{
+			ID:        revision.ID.Hex(),
+			Action:    revision.Action,
+			ActorID:   revision.ActorId.Hex(),
+			Changes:   revision.Changes,
+			State:     revision.State,
+			CreatedAt: revision.CreatedAt,
+		}
+		if revision.RevertedFrom != nil {
+			response.RevertedFrom = revision.RevertedFrom.Hex()
+		}
+		revisionsResponse = append(revisionsResponse, response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"history": revisionsResponse,
+	})
+}
+
+func RevertTask(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	revisionID, err := primitive.ObjectIDFromHex(c.Params("revision"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid revision ID",
+		})
+	}
+
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if !utils.IfMatch(ifMatch, task.Version) {
+		return preconditionFailed(c)
+	}
+
+	revision := new(models.Revision)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("HISTORY_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": revisionID, "task_id": task.ID}).Decode(revision); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Revision not found",
+		})
+	}
+
+	before := utils.TaskStateOf(task)
+	changes := utils.DiffTaskStates(before, &revision.State)
+	if len(changes) == 0 {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   false,
+			"message": "Task already matches the revision",
+			"changes": changes,
+		})
+	}
+
+	// Only the tracked fields are restored, dates, labels and the place of
+	// the task stay as they are
+	set := bson.M{
+		"title":       revision.State.Title,
+		"completed":   revision.State.Completed,
+		"search_text": utils.TaskSearchText(revision.State.Metadata),
+		"updated_at":  time.Now().Unix(),
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(revision.State.Metadata) > 0 {
+		set["metadata"] = revision.State.Metadata
+	} else {
+		update["$unset"] = bson.M{"metadata": ""}
+	}
+
+	// The changes were worked out from the task as it was read, a write in
+	// between would be lost
+	filter := fiber.Map{"_id": task.ID, "user_id": task.UserId, "deleted_at": bson.M{"$exists": false}, "version": utils.VersionFilter(task.Version)}
+
+	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateOne(c.Context(), filter, update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 && utils.IfMatchChecksVersion(ifMatch) {
+		return preconditionFailed(c)
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task was changed while it was reverted, try again",
+		})
+	}
+
+	if revision.State.Completed && !task.Completed {
+		if err := cancelTaskReminders(c.Context(), db, task.ID); err != nil {
+			log.Printf("Cancelling reminders of task %s failed: %v\n", task.ID.Hex(), err)
+		}
+	} else if !revision.State.Completed && task.Completed {
+		if err := resumeTaskReminders(c.Context(), db, task.ID, task.DueAt); err != nil {
+			log.Printf("Resuming reminders of task %s failed: %v\n", task.ID.Hex(), err)
+		}
+	}
+
+	if err := recordRevision(c.Context(), db, user.ID, task.ID, models.RevisionRevert, before, &revision.State, &revision.ID); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", task.ID.Hex(), err)
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, []models.Task{*task})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Task reverted successfully",
+		"changes": changes,
+	})
+}
+
+// recordRevision func to add a change of a task to its history. Updates that
+// leave every tracked field as it was are not recorded.
+func recordRevision(ctx context.Context, db *mongo.Database, actorID, taskID primitive.ObjectID, action string, before, after *models.TaskState, revertedFrom *primitive.ObjectID) error {
+	revision := newRevision(actorID, taskID, action, before, after)
+	if action != models.RevisionCreate && action != models.RevisionDelete && len(revision.Changes) == 0 {
+		return nil
+	}
+	revision.RevertedFrom = revertedFrom
+
+	_, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).InsertOne(ctx, revision)
+	return err
+}
+
+// recordRevisions func to add the same kind of change of many tasks to their
+// history at once, such as completing or deleting every subtask of a task.
+func recordRevisions(ctx context.Context, db *mongo.Database, actorID primitive.ObjectID, action string, tasks []models.Task, change func(*models.TaskState) *models.TaskState) error {
+	revisions := []interface{}{}
+	for _, task := range tasks {
+		before := utils.TaskStateOf(&task)
+		revision := newRevision(actorID, task.ID, action, before, change(before))
+		if action == models.RevisionUpdate && len(revision.Changes) == 0 {
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	_, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).InsertMany(ctx, revisions)
+	return err
+}
+
+func newRevision(actorID, taskID primitive.ObjectID, action string, before, after *models.TaskState) *models.Revision {
+	revision := &models.Revision{
+		TaskId:    taskID,
+		ActorId:   actorID,
+		Action:    action,
+		Changes:   utils.DiffTaskStates(before, after),
+		CreatedAt: time.Now().Unix(),
+	}
+
+	// Deletes keep the last state of the task so it can be looked up later
+	if after != nil {
+		revision.State = *after
+	} else if before != nil {
+		revision.State = *before
+	}
+
+	return revision
+}
+
+func deleteTaskHistory(ctx context.Context, db *mongo.Database, taskIDs ...primitive.ObjectID) error {
+	_, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).DeleteMany(ctx, bson.M{"task_id": bson.M{"$in": taskIDs}})
+
+	return err
+}
@@ -0,0 +1,452 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+var errLabelNotFound = errors.New("Label not found")
+
+func CreateLabel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	createLabel := new(models.CreateLabel)
+	if err := c.BodyParser(&createLabel); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createLabel); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	label := new(models.Label)
+	label.UserId = user.ID
+	label.Name = createLabel.Name
+	label.Color = createLabel.Color
+	label.Description = createLabel.Description
+	label.CreatedAt = timestamp
+	label.UpdatedAt = timestamp
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("LABELS_COLLECTION")).InsertOne(c.Context(), label)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Label created successfully",
+		"label":   res.InsertedID,
+	})
+}
+
+func GetLabels(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var labels []models.Label
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("LABELS_COLLECTION")).Find(c.Context(), fiber.Map{"user_id": user.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &labels); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	labelsResponse := make([]models.GetLabel, 0, len(labels))
+	for _, label := range labels {
+		labelsResponse = append(labelsResponse, labelResponse(&label))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":  false,
+		"labels": labelsResponse,
+	})
+}
+
+func GetLabel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid label ID",
+		})
+	}
+
+	label := new(models.Label)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("LABELS_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}).Decode(&label); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"label": labelResponse(label),
+	})
+}
+
+func UpdateLabel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid label ID",
+		})
+	}
+
+	updateLabel := new(models.UpdateLabel)
+	if err := c.BodyParser(&updateLabel); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateLabel); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	update := bson.M{"updated_at": time.Now().Unix()}
+	if updateLabel.Name != nil {
+		update["name"] = *updateLabel.Name
+	}
+	if updateLabel.Color != nil {
+		update["color"] = *updateLabel.Color
+	}
+	if updateLabel.Description != nil {
+		update["description"] = *updateLabel.Description
+	}
+
+	// Tasks only hold the label ID, so a rename is a single document update
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("LABELS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, bson.M{"$set": update})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label already exists",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Label updated successfully",
+	})
+}
+
+func DeleteLabel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid label ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	deleted, labelled, err := deleteLabel(c.Context(), db, user.ID, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, labelled)
+
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Label deleted successfully",
+	})
+}
+
+// deleteLabel func to delete a label of a user and every reference to it,
+// together when the server has transactions. Without them the label is taken
+// off the tasks first, so a failure leaves a label without tasks rather than
+// tasks with a label that is gone. The tasks that had the label are returned
+// as they were before.
+func deleteLabel(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID) (bool, []models.Task, error) {
+	var labelled []models.Task
+
+	deleted, err := withTransaction(ctx, db, func(ctx context.Context) (interface{}, error) {
+		tasks := db.Collection(os.Getenv("TASKS_COLLECTION"))
+		filter := bson.M{"user_id": userID, "labels": id}
+
+		labelled = []models.Task{}
+		cursor, err := tasks.Find(ctx, filter)
+		if err != nil {
+			return false, err
+		}
+		if err := cursor.All(ctx, &labelled); err != nil {
+			return false, err
+		}
+
+		if len(labelled) > 0 {
+			if _, err := tasks.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": taskIDs(labelled)}, "labels": id}, bson.M{"$pull": bson.M{"labels": id}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}}); err != nil {
+				return false, err
+			}
+		}
+
+		res, err := db.Collection(os.Getenv("LABELS_COLLECTION")).DeleteOne(ctx, fiber.Map{"_id": id, "user_id": userID})
+		if err != nil {
+			return false, err
+		}
+
+		return res.DeletedCount > 0, nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return deleted.(bool), labelled, nil
+}
+
+func AssignTaskLabels(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	validate := validator.New()
+
+	assignLabels := new(models.AssignLabels)
+	if err := c.BodyParser(&assignLabels); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(assignLabels); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	// Tasks of a shared project use the labels of the project owner
+	labelIDs, err := userLabelIDs(c.Context(), db, task.UserId, assignLabels.LabelIDs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": task.ID, "user_id": task.UserId}, bson.M{"$addToSet": bson.M{"labels": bson.M{"$each": labelIDs}}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task not found",
+		})
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, []models.Task{*task})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Labels assigned successfully",
+	})
+}
+
+func RemoveTaskLabel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	labelID, err := primitive.ObjectIDFromHex(c.Params("labelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid label ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": task.ID, "user_id": task.UserId, "labels": labelID}, bson.M{"$pull": bson.M{"labels": labelID}, "$set": bson.M{"updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Label not assigned to task",
+		})
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, []models.Task{*task})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Label removed successfully",
+	})
+}
+
+// userLabelIDs func to parse label IDs and make sure every one of them
+// belongs to the user.
+func userLabelIDs(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, hexIDs []string) ([]primitive.ObjectID, error) {
+	ids := make([]primitive.ObjectID, 0, len(hexIDs))
+	for _, hexID := range hexIDs {
+		id, err := primitive.ObjectIDFromHex(hexID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	count, err := db.Collection(os.Getenv("LABELS_COLLECTION")).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	ids = uniqueIDs(ids)
+	if count != int64(len(ids)) {
+		return nil, errLabelNotFound
+	}
+
+	return ids, nil
+}
+
+// fillTaskLabels func to resolve the label IDs of the tasks into the names and
+// colors of their responses.
+func fillTaskLabels(ctx context.Context, db *mongo.Database, tasks []models.Task, responses []models.GetTask) error {
+	ids := []primitive.ObjectID{}
+	for _, task := range tasks {
+		ids = append(ids, task.Labels...)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var labels []models.Label
+	cursor, err := db.Collection(os.Getenv("LABELS_COLLECTION")).Find(ctx, bson.M{"_id": bson.M{"$in": uniqueIDs(ids)}})
+	if err != nil {
+		return err
+	}
+
+	if err := cursor.All(ctx, &labels); err != nil {
+		return err
+	}
+
+	byID := map[primitive.ObjectID]models.GetLabel{}
+	for _, label := range labels {
+		byID[label.ID] = models.GetLabel{ID: label.ID.Hex(), Name: label.Name, Color: label.Color}
+	}
+
+	for i, task := range tasks {
+		for _, id := range task.Labels {
+			if label, ok := byID[id]; ok {
+				responses[i].Labels = append(responses[i].Labels, label)
+			}
+		}
+	}
+
+	return nil
+}
+
+func labelResponse(label *models.Label) models.GetLabel {
+	return models.GetLabel{
+		ID:          label.ID.Hex(),
+		Name:        label.Name,
+		Color:       label.Color,
+		Description: label.Description,
+		CreatedAt:   label.CreatedAt,
+		UpdatedAt:   label.UpdatedAt,
+	}
+}
+
+func uniqueIDs(ids []primitive.ObjectID) []primitive.ObjectID {
+	seen := map[primitive.ObjectID]bool{}
+	unique := []primitive.ObjectID{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
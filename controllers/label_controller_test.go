@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestDeleteLabelStandalone(t *testing.T) {
+	os.Setenv("TASKS_COLLECTION", "tasks")
+	os.Setenv("LABELS_COLLECTION", "labels")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("label is taken off the tasks before it is deleted", func(mt *mtest.T) {
+		userID, id := primitive.NewObjectID(), primitive.NewObjectID()
+
+		// A standalone server answers hello without a replica set name
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true}),
+			mtest.CreateCursorResponse(0, "db.tasks", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "user_id", Value: userID}, {Key: "labels", Value: bson.A{id}}},
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "user_id", Value: userID}, {Key: "labels", Value: bson.A{id}}},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		deleted, labelled, err := deleteLabel(context.Background(), mt.DB, userID, id)
+		if err != nil || !deleted {
+			mt.Fatalf("deleteLabel = %v, %v", deleted, err)
+		}
+		if len(labelled) != 2 {
+			mt.Errorf("deleteLabel returned %d labelled tasks, want 2", len(labelled))
+		}
+
+		commands := []string{}
+		for event := mt.GetStartedEvent(); event != nil; event = mt.GetStartedEvent() {
+			commands = append(commands, event.CommandName)
+			if _, err := event.Command.LookupErr("startTransaction"); err == nil {
+				mt.Errorf("%s started a transaction", event.CommandName)
+			}
+		}
+		if len(commands) != 4 || commands[0] != "hello" || commands[1] != "find" || commands[2] != "update" || commands[3] != "delete" {
+			mt.Errorf("commands = %v, want hello, find, update, delete", commands)
+		}
+	})
+	mt.Run("missing label", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true}),
+			mtest.CreateCursorResponse(0, "db.tasks", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+		)
+
+		if deleted, _, err := deleteLabel(context.Background(), mt.DB, primitive.NewObjectID(), primitive.NewObjectID()); err != nil || deleted {
+			mt.Errorf("deleteLabel = %v, %v, want not deleted", deleted, err)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func GetProjectMembers(c *fiber.Ctx) error {
+	project := c.Locals("project").(*models.Project)
+
+	ids := []primitive.ObjectID{project.UserId}
+	for _, member := range project.Members {
+		ids = append(ids, member.UserId)
+	}
+
+	var users []models.User
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("USER_COLLECTION")).Find(c.Context(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &users); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	byID := map[primitive.ObjectID]models.User{}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	// The owner of the project comes first, then the members in the order
+	// they were added
+	members := append([]models.ProjectMember{{UserId: project.UserId, Role: models.RoleOwner, AddedAt: project.CreatedAt}}, project.Members...)
+
+	membersResponse := make([]models.GetMember, 0, len(members))
+	for _, member := range members {
+		user, ok := byID[member.UserId]
+		if !ok {
+			continue
+		}
+
+		membersResponse = append(membersResponse, models.GetMember{
+			UserID:    member.UserId.Hex(),
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+			Role:      member.Role,
+			AddedAt:   member.AddedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"members": membersResponse,
+	})
+}
+
+func AddProjectMember(c *fiber.Ctx) error {
+	project := c.Locals("project").(*models.Project)
+	validate := validator.New()
+
+	addMember := new(models.AddMember)
+	if err := c.BodyParser(&addMember); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(addMember); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if project.Inbox {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "The Inbox cannot be shared",
+		})
+	}
+
+	member := new(models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("USER_COLLECTION")).FindOne(c.Context(), fiber.Map{"email": addMember.Email}).Decode(&member); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "User not found",
+		})
+	}
+
+	if utils.ProjectRole(project, member.ID) != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "User is already a member of the project",
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	// The filter keeps two concurrent invites from adding the user twice
+	res, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).UpdateOne(c.Context(),
+		bson.M{"_id": project.ID, "members.user_id": bson.M{"$ne": member.ID}},
+		bson.M{
+			"$push": bson.M{"members": models.ProjectMember{UserId: member.ID, Role: addMember.Role, AddedAt: timestamp}},
+			"$set":  bson.M{"updated_at": timestamp},
+		})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "User is already a member of the project",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Member added successfully",
+		"member":  member.ID,
+	})
+}
+
+func UpdateProjectMember(c *fiber.Ctx) error {
+	project := c.Locals("project").(*models.Project)
+	validate := validator.New()
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid user ID",
+		})
+	}
+
+	updateMember := new(models.UpdateMember)
+	if err := c.BodyParser(&updateMember); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateMember); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if memberID == project.UserId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "The role of the project owner cannot be changed",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).UpdateOne(c.Context(),
+		bson.M{"_id": project.ID, "members.user_id": memberID},
+		bson.M{"$set": bson.M{"members.$.role": updateMember.Role, "updated_at": time.Now().Unix()}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Member not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Member updated successfully",
+	})
+}
+
+func RemoveProjectMember(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	project := c.Locals("project").(*models.Project)
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid user ID",
+		})
+	}
+
+	if memberID == project.UserId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "The project owner cannot be removed",
+		})
+	}
+
+	// Members can always leave a project, removing someone else takes an owner
+	if memberID != user.ID && !utils.RoleAllows(utils.ProjectRole(project, user.ID), models.RoleOwner) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "You do not have permission to do this",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).UpdateOne(c.Context(),
+		bson.M{"_id": project.ID, "members.user_id": memberID},
+		bson.M{"$pull": bson.M{"members": bson.M{"user_id": memberID}}, "$set": bson.M{"updated_at": time.Now().Unix()}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Member not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Member removed successfully",
+	})
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func GetNotifications(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	limit := c.QueryInt("limit", utils.DefaultPageLimit)
+	if limit < 1 || limit > utils.MaxPageLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "limit must be a number between 1 and " + strconv.Itoa(utils.MaxPageLimit),
+		})
+	}
+
+	filter := bson.M{"user_id": user.ID}
+	if c.QueryBool("unread") {
+		filter["read"] = false
+	}
+
+	var notifications []models.Notification
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("NOTIFICATIONS_COLLECTION")).Find(c.Context(), filter, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &notifications); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	notificationsResponse := make([]models.GetNotification, 0, len(notifications))
+	for _, notification := range notifications {
+		response := models.GetNotification{
+			ID:        notification.ID.Hex(),
+			Type:      notification.Type,
+			ActorID:   notification.ActorId.Hex(),
+			TaskID:    notification.TaskId.Hex(),
+			Read:      notification.Read,
+			CreatedAt: notification.CreatedAt,
+		}
+		if notification.CommentId != nil {
+			response.CommentID = notification.CommentId.Hex()
+		}
+		notificationsResponse = append(notificationsResponse, response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":         false,
+		"notifications": notificationsResponse,
+	})
+}
+
+func ReadNotification(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid notification ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("NOTIFICATIONS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Notification not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Notification marked as read",
+	})
+}
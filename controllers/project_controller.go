@@ -0,0 +1,493 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+var (
+	errProjectNotFound = errors.New("Project not found")
+	errProjectArchived = errors.New("Project is archived")
+)
+
+func CreateProject(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	createProject := new(models.CreateProject)
+	if err := c.BodyParser(&createProject); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createProject); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	project := new(models.Project)
+	project.UserId = user.ID
+	project.Name = createProject.Name
+	project.Color = createProject.Color
+	project.Description = createProject.Description
+	project.CreatedAt = timestamp
+	project.UpdatedAt = timestamp
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).InsertOne(c.Context(), project)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Project created successfully",
+		"project": res.InsertedID,
+	})
+}
+
+func GetProjects(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	// Users from before projects existed get their Inbox on first listing
+	if _, err := userInbox(c.Context(), db, user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	var projects []models.Project
+
+	opts := options.Find().SetSort(bson.D{{Key: "inbox", Value: -1}, {Key: "name", Value: 1}})
+
+	filter := bson.M{
+		"$or":      bson.A{bson.M{"user_id": user.ID}, bson.M{"members.user_id": user.ID}},
+		"archived": c.QueryBool("archived", false),
+	}
+
+	cursor, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).Find(c.Context(), filter, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &projects); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	counts, err := projectTaskCounts(c.Context(), db, projectIDs(projects))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	projectsResponse := make([]models.GetProject, 0, len(projects))
+	for _, project := range projects {
+		projectsResponse = append(projectsResponse, projectResponse(&project, user.ID, counts[project.ID]))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    false,
+		"projects": projectsResponse,
+	})
+}
+
+func GetProject(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	project := c.Locals("project").(*models.Project)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	counts, err := projectTaskCounts(c.Context(), db, []primitive.ObjectID{project.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"project": projectResponse(project, user.ID, counts[project.ID]),
+	})
+}
+
+func UpdateProject(c *fiber.Ctx) error {
+	project := c.Locals("project").(*models.Project)
+	validate := validator.New()
+
+	updateProject := new(models.UpdateProject)
+	if err := c.BodyParser(&updateProject); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateProject); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	update := bson.M{"updated_at": time.Now().Unix()}
+	if updateProject.Name != nil {
+		update["name"] = *updateProject.Name
+	}
+	if updateProject.Color != nil {
+		update["color"] = *updateProject.Color
+	}
+	if updateProject.Description != nil {
+		update["description"] = *updateProject.Description
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	if _, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": project.ID}, bson.M{"$set": update}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Project updated successfully",
+	})
+}
+
+func DeleteProject(c *fiber.Ctx) error {
+	project := c.Locals("project").(*models.Project)
+	id := project.ID
+
+	if project.Inbox {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "The Inbox cannot be deleted",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	inbox, err := userInbox(c.Context(), db, project.UserId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	deleted, err := deleteProject(c.Context(), db, id, inbox.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Project not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Project deleted successfully",
+	})
+}
+
+// deleteProject func to delete a project and move its tasks to the Inbox of
+// its owner, together when the server has transactions. Without them the
+// tasks are moved first, so a failure never leaves tasks in a project that is
+// gone.
+func deleteProject(ctx context.Context, db *mongo.Database, id, inboxID primitive.ObjectID) (bool, error) {
+	deleted, err := withTransaction(ctx, db, func(ctx context.Context) (interface{}, error) {
+		if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateMany(ctx, bson.M{"project_id": id}, bson.M{"$set": bson.M{"project_id": inboxID, "updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}}); err != nil {
+			return false, err
+		}
+
+		res, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).DeleteOne(ctx, fiber.Map{"_id": id})
+		if err != nil {
+			return false, err
+		}
+
+		return res.DeletedCount > 0, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted.(bool), nil
+}
+
+func ArchiveProject(c *fiber.Ctx) error {
+	return setProjectArchived(c, true)
+}
+
+func UnarchiveProject(c *fiber.Ctx) error {
+	return setProjectArchived(c, false)
+}
+
+func MoveTaskToProject(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	id := task.ID
+	validate := validator.New()
+
+	moveTask := new(models.MoveTaskProject)
+	if err := c.BodyParser(&moveTask); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(moveTask); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	projectID, _ := primitive.ObjectIDFromHex(moveTask.ProjectID)
+	project, err := activeProject(c.Context(), db, user.ID, projectID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	// Subtasks, dependencies and labels are scoped to the owner of a task
+	if project.UserId != task.UserId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Tasks can only be moved between projects of the same owner",
+		})
+	}
+
+	// Subtasks travel with their task, a subtask moved on its own leaves its
+	// parent behind and becomes a top level task of the project
+	descendants, err := taskDescendants(c.Context(), db, task.UserId, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	update := bson.M{"$set": bson.M{"project_id": projectID, "updated_at": timestamp}, "$inc": bson.M{"version": 1}}
+	if task.ParentId != nil {
+		update["$unset"] = bson.M{"parent_id": ""}
+	}
+
+	if _, err := collection.UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": task.UserId}, update); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if len(descendants) > 0 {
+		if _, err := collection.UpdateMany(c.Context(), bson.M{"_id": bson.M{"$in": taskIDs(descendants)}}, bson.M{"$set": bson.M{"project_id": projectID, "updated_at": timestamp}, "$inc": bson.M{"version": 1}}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+	}
+
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, append([]models.Task{*task}, descendants...))
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Task moved successfully",
+	})
+}
+
+func setProjectArchived(c *fiber.Ctx, archived bool) error {
+	project := c.Locals("project").(*models.Project)
+
+	if project.Inbox {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "The Inbox cannot be archived",
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	update := bson.M{"$set": bson.M{"archived": false, "updated_at": timestamp}, "$unset": bson.M{"archived_at": ""}}
+	if archived {
+		update = bson.M{"$set": bson.M{"archived": true, "archived_at": timestamp, "updated_at": timestamp}}
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	if _, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": project.ID}, update); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	message := "Project unarchived successfully"
+	if archived {
+		message = "Project archived successfully"
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": message,
+	})
+}
+
+// userInbox func to load the Inbox of the user, creating it when it does not
+// exist yet. A freshly created Inbox adopts the tasks that predate projects.
+func userInbox(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (*models.Project, error) {
+	collection := db.Collection(os.Getenv("PROJECTS_COLLECTION"))
+	filter := bson.M{"user_id": userID, "inbox": true}
+	timestamp := time.Now().Unix()
+
+	res, err := collection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": bson.M{
+		"name":       "Inbox",
+		"archived":   false,
+		"created_at": timestamp,
+		"updated_at": timestamp,
+	}}, options.Update().SetUpsert(true))
+
+	// Two concurrent upserts race on the unique index, the loser reads the
+	// Inbox the winner created
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	inbox := new(models.Project)
+	if err := collection.FindOne(ctx, filter).Decode(inbox); err != nil {
+		return nil, err
+	}
+
+	if res != nil && res.UpsertedID != nil {
+		if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateMany(ctx, bson.M{"user_id": userID, "project_id": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"project_id": inbox.ID}, "$inc": bson.M{"version": 1}}); err != nil {
+			return nil, err
+		}
+	}
+
+	return inbox, nil
+}
+
+// activeProject func to load a project the user can add tasks to.
+func activeProject(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID) (*models.Project, error) {
+	project, err := utils.ProjectAccess(ctx, db, userID, id, models.RoleEditor)
+	if err == utils.ErrForbidden {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errProjectNotFound
+	}
+
+	if project.Archived {
+		return nil, errProjectArchived
+	}
+
+	return project, nil
+}
+
+// projectTaskCounts func to count the tasks of each project and how many of
+// them are completed.
+func projectTaskCounts(ctx context.Context, db *mongo.Database, ids []primitive.ObjectID) (map[primitive.ObjectID]*models.TaskProgress, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"project_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$project_id",
+			"total": bson.M{"$sum": 1},
+			"done":  bson.M{"$sum": bson.M{"$cond": bson.A{"$completed", 1, 0}}},
+		}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Done  int64              `bson:"done"`
+		Total int64              `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := map[primitive.ObjectID]*models.TaskProgress{}
+	for _, id := range ids {
+		counts[id] = &models.TaskProgress{}
+	}
+	for _, result := range results {
+		counts[result.ID] = &models.TaskProgress{Done: result.Done, Total: result.Total}
+	}
+
+	return counts, nil
+}
+
+func projectResponse(project *models.Project, userID primitive.ObjectID, tasks *models.TaskProgress) models.GetProject {
+	return models.GetProject{
+		ID:          project.ID.Hex(),
+		Name:        project.Name,
+		Color:       project.Color,
+		Description: project.Description,
+		Inbox:       project.Inbox,
+		Archived:    project.Archived,
+		Role:        utils.ProjectRole(project, userID),
+		ArchivedAt:  project.ArchivedAt,
+		Tasks:       tasks,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+	}
+}
+
+func projectIDs(projects []models.Project) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(projects))
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+	}
+
+	return ids
+}
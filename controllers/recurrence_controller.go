@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const maxOccurrencePreview = 50
+
+func GetTaskOccurrences(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	count := c.QueryInt("count", 5)
+	if count < 1 || count > maxOccurrencePreview {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "count must be a number between 1 and " + strconv.Itoa(maxOccurrencePreview),
+		})
+	}
+
+	if task.Recurrence == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task does not recur",
+		})
+	}
+
+	occurrences, err := utils.TaskOccurrences(task, count)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":       false,
+		"recurrence":  task.Recurrence,
+		"occurrences": occurrences,
+	})
+}
+
+// createNextOccurrence func to insert the next occurrence of a recurring task
+// that was just completed, along with its offset reminders. It returns nil
+// when the task does not recur, its series is over or the occurrence was
+// already created by an earlier or concurrent completion.
+func createNextOccurrence(ctx context.Context, db *mongo.Database, actorID, id primitive.ObjectID) (interface{}, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	task := new(models.Task)
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(task); err != nil {
+		return nil, err
+	}
+
+	if task.Recurrence == "" {
+		return nil, nil
+	}
+
+	next, err := utils.NextTaskOccurrence(task)
+	if err != nil || next == nil {
+		return nil, err
+	}
+
+	res, err := collection.InsertOne(ctx, next)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next.ID = res.InsertedID.(primitive.ObjectID)
+	if err := recordRevision(ctx, db, actorID, next.ID, models.RevisionCreate, nil, utils.TaskStateOf(next), nil); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", next.ID.Hex(), err)
+	}
+
+	publishTaskEvent(db, models.EventTaskCreated, actorID, next, nil)
+
+	if err := copyOffsetReminders(ctx, db, task.ID, next); err != nil {
+		return nil, err
+	}
+
+	return res.InsertedID, nil
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func CreateReminder(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	validate := validator.New()
+
+	createReminder := new(models.CreateReminder)
+	if err := c.BodyParser(&createReminder); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createReminder); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if task.Completed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task is already completed",
+		})
+	}
+
+	timestamp := time.Now().Unix()
+
+	reminder := new(models.Reminder)
+	reminder.TaskId = task.ID
+	reminder.UserId = user.ID
+	reminder.Channel = createReminder.Channel
+	reminder.Target = createReminder.Target
+	reminder.Status = models.ReminderPending
+	reminder.CreatedAt = timestamp
+	reminder.UpdatedAt = timestamp
+
+	if createReminder.Offset != nil {
+		if task.DueAt == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Offset reminders need a task with a due date",
+			})
+		}
+		reminder.Offset = createReminder.Offset
+		reminder.RemindAt = *task.DueAt - *createReminder.Offset
+	} else {
+		reminder.RemindAt = *createReminder.RemindAt
+	}
+	reminder.NextAttemptAt = reminder.RemindAt
+
+	if reminder.RemindAt <= timestamp {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Reminder time is in the past",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).InsertOne(c.Context(), reminder)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":    false,
+		"message":  "Reminder created successfully",
+		"reminder": res.InsertedID,
+	})
+}
+
+func GetReminders(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	var reminders []models.Reminder
+
+	opts := options.Find().SetSort(bson.D{{Key: "remind_at", Value: 1}})
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).Find(c.Context(), fiber.Map{"task_id": task.ID, "user_id": user.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &reminders); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	remindersResponse := make([]models.GetReminder, 0, len(reminders))
+	for _, reminder := range reminders {
+		remindersResponse = append(remindersResponse, models.GetReminder{
+			ID:        reminder.ID.Hex(),
+			TaskID:    reminder.TaskId.Hex(),
+			RemindAt:  reminder.RemindAt,
+			Offset:    reminder.Offset,
+			Channel:   reminder.Channel,
+			Target:    reminder.Target,
+			Status:    reminder.Status,
+			Attempts:  reminder.Attempts,
+			SentAt:    reminder.SentAt,
+			CreatedAt: reminder.CreatedAt,
+			UpdatedAt: reminder.UpdatedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":     false,
+		"reminders": remindersResponse,
+	})
+}
+
+func DeleteReminder(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	reminderID, err := primitive.ObjectIDFromHex(c.Params("reminderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid reminder ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).DeleteOne(c.Context(), fiber.Map{"_id": reminderID, "task_id": task.ID, "user_id": user.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Reminder not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Reminder deleted successfully",
+	})
+}
+
+// cancelTaskReminders func to stop every reminder of the completed tasks that
+// has not fired yet. They are marked so reopening the task resumes them.
+func cancelTaskReminders(ctx context.Context, db *mongo.Database, taskIDs ...primitive.ObjectID) error {
+	_, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).UpdateMany(ctx,
+		bson.M{"task_id": bson.M{"$in": taskIDs}, "status": models.ReminderPending},
+		bson.M{"$set": bson.M{"status": models.ReminderCancelled, "cancelled_on_completion": true, "updated_at": time.Now().Unix()}})
+
+	return err
+}
+
+// resumeTaskReminders func to give a reopened task back the reminders its
+// completion cancelled. Offset reminders follow the current due date, those
+// whose time has passed stay cancelled.
+func resumeTaskReminders(ctx context.Context, db *mongo.Database, taskID primitive.ObjectID, dueAt *int64) error {
+	collection := db.Collection(os.Getenv("REMINDERS_COLLECTION"))
+	filter := bson.M{"task_id": taskID, "status": models.ReminderCancelled, "cancelled_on_completion": true}
+	timestamp := time.Now().Unix()
+
+	absolute := bson.M{"offset": bson.M{"$exists": false}, "remind_at": bson.M{"$gt": timestamp}}
+	if _, err := collection.UpdateMany(ctx, bson.M{"$and": bson.A{filter, absolute}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"status": models.ReminderPending, "next_attempt_at": "$remind_at", "attempts": 0, "updated_at": timestamp}}},
+		{{Key: "$unset", Value: "cancelled_on_completion"}},
+	}); err != nil {
+		return err
+	}
+
+	if dueAt == nil {
+		return nil
+	}
+
+	remindAt := bson.M{"$subtract": bson.A{*dueAt, "$offset"}}
+	offset := bson.M{"offset": bson.M{"$exists": true, "$lt": *dueAt - timestamp}}
+	_, err := collection.UpdateMany(ctx, bson.M{"$and": bson.A{filter, offset}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"status": models.ReminderPending, "remind_at": remindAt, "next_attempt_at": remindAt, "attempts": 0, "updated_at": timestamp}}},
+		{{Key: "$unset", Value: "cancelled_on_completion"}},
+	})
+
+	return err
+}
+
+// rescheduleTaskReminders func to move offset reminders along with the due date
+// of their task. Clearing the due date cancels them.
+func rescheduleTaskReminders(ctx context.Context, db *mongo.Database, taskID primitive.ObjectID, dueAt *int64) error {
+	collection := db.Collection(os.Getenv("REMINDERS_COLLECTION"))
+	filter := bson.M{"task_id": taskID, "status": models.ReminderPending, "offset": bson.M{"$exists": true}}
+	timestamp := time.Now().Unix()
+
+	if dueAt == nil {
+		_, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": models.ReminderCancelled, "updated_at": timestamp}})
+		return err
+	}
+
+	remindAt := bson.M{"$subtract": bson.A{*dueAt, "$offset"}}
+	_, err := collection.UpdateMany(ctx, filter, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"remind_at": remindAt, "next_attempt_at": remindAt, "attempts": 0, "updated_at": timestamp}}},
+	})
+
+	return err
+}
+
+// copyOffsetReminders func to give the next occurrence of a recurring task the
+// same offset reminders as the one before it.
+func copyOffsetReminders(ctx context.Context, db *mongo.Database, fromTaskID primitive.ObjectID, task *models.Task) error {
+	if task.DueAt == nil {
+		return nil
+	}
+
+	collection := db.Collection(os.Getenv("REMINDERS_COLLECTION"))
+
+	var reminders []models.Reminder
+	cursor, err := collection.Find(ctx, bson.M{"task_id": fromTaskID, "offset": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+
+	if err := cursor.All(ctx, &reminders); err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	copies := []interface{}{}
+	for _, reminder := range reminders {
+		remindAt := *task.DueAt - *reminder.Offset
+		if remindAt <= timestamp {
+			continue
+		}
+
+		copies = append(copies, models.Reminder{
+			TaskId:        task.ID,
+			UserId:        reminder.UserId,
+			RemindAt:      remindAt,
+			Offset:        reminder.Offset,
+			Channel:       reminder.Channel,
+			Target:        reminder.Target,
+			Status:        models.ReminderPending,
+			NextAttemptAt: remindAt,
+			CreatedAt:     timestamp,
+			UpdatedAt:     timestamp,
+		})
+	}
+
+	if len(copies) == 0 {
+		return nil
+	}
+
+	_, err = collection.InsertMany(ctx, copies)
+
+	return err
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/stream"
+)
+
+const (
+	streamHeartbeat = 25 * time.Second
+	streamRetry     = 3000
+)
+
+// StreamTasks func to push the task events of the user as Server-Sent
+// Events. A session resumes with the Last-Event-ID header EventSource sends
+// on reconnect, or last_event_id on its first connection. A reset event asks
+// it to reload its tasks when the events it missed are no longer known.
+func StreamTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	hub := c.Locals("hub").(*stream.Hub)
+
+	subscription, missed, ok := hub.Subscribe(c.Context(), user.ID, c.Get("Last-Event-ID", c.Query("last_event_id")))
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// Proxies like nginx buffer responses unless told otherwise
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer hub.Unsubscribe(subscription)
+
+		w.WriteString("retry: " + strconv.Itoa(streamRetry) + "\n\n")
+		if !ok {
+			w.WriteString("event: reset\ndata: {}\n\n")
+		}
+		for _, event := range missed {
+			writeServerSentEvent(w, event)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, open := <-subscription.Events:
+				// A session that fell behind reconnects and resumes
+				if !open {
+					return
+				}
+				writeServerSentEvent(w, event)
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+// UpgradeTaskSocket func to only let WebSocket handshakes through to
+// TaskSocket.
+func UpgradeTaskSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+			"error":   true,
+			"message": "Expected a WebSocket handshake",
+		})
+	}
+
+	return c.Next()
+}
+
+// TaskSocket func to push the task events of the user as JSON messages over a
+// WebSocket. A session resumes with last_event_id, messages from the client
+// are ignored.
+func TaskSocket(conn *websocket.Conn) {
+	user := conn.Locals("user").(*models.User)
+	hub := conn.Locals("hub").(*stream.Hub)
+
+	subscription, missed, ok := hub.Subscribe(context.Background(), user.ID, conn.Query("last_event_id"))
+	defer hub.Unsubscribe(subscription)
+
+	// Reading handles pings and the close of the client and tells when the
+	// connection is gone
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	// The connection goes back to a pool once this returns, the reader has to
+	// be done with it by then
+	defer func() {
+		conn.Close()
+		<-closed
+	}()
+
+	if !ok {
+		if err := conn.WriteJSON(fiber.Map{"event": "reset"}); err != nil {
+			return
+		}
+	}
+	for _, event := range missed {
+		if err := conn.WriteJSON(streamMessage(event)); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-closed:
+			return
+		case event, open := <-subscription.Events:
+			if !open {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Too far behind, resume from the last event"), time.Now().Add(time.Second))
+				return
+			}
+			err = conn.WriteJSON(streamMessage(event))
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamHeartbeat))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(w *bufio.Writer, event stream.Event) {
+	data, err := json.Marshal(streamMessage(event))
+	if err != nil {
+		log.Printf("Encoding stream event %s failed: %v\n", event.ID, err)
+		return
+	}
+
+	w.WriteString("id: " + event.ID + "\nevent: " + event.Type + "\ndata: ")
+	w.Write(data)
+	w.WriteString("\n\n")
+}
+
+func streamMessage(event stream.Event) fiber.Map {
+	return fiber.Map{
+		"id":         event.ID,
+		"event":      event.Type,
+		"created_at": event.CreatedAt,
+		"task":       taskResponse(&event.Task),
+	}
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func GetTaskChildren(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	var tasks []models.Task
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := collection.Find(c.Context(), fiber.Map{"parent_id": task.ID, "user_id": task.UserId, "deleted_at": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	tasksResponse := make([]models.GetTask, 0, len(tasks))
+	for _, task := range tasks {
+		tasksResponse = append(tasksResponse, taskResponse(&task))
+	}
+
+	if err := fillTaskLabels(c.Context(), db, tasks, tasksResponse); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"tasks": tasksResponse,
+	})
+}
+
+func GetTaskTree(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	descendants, err := taskDescendants(c.Context(), db, task.UserId, task.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	tasks := append([]models.Task{*task}, descendants...)
+	responses := make([]models.GetTask, 0, len(tasks))
+	for i := range tasks {
+		responses = append(responses, taskResponse(&tasks[i]))
+	}
+
+	if err := fillTaskLabels(c.Context(), db, tasks, responses); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Index the nodes first, then hang every node below its parent
+	nodes := map[primitive.ObjectID]*models.TaskTree{}
+	for i := range tasks {
+		nodes[tasks[i].ID] = &models.TaskTree{GetTask: responses[i], Children: []*models.TaskTree{}}
+	}
+	root := nodes[task.ID]
+	for _, descendant := range descendants {
+		if parent, ok := nodes[*descendant.ParentId]; ok {
+			parent.Children = append(parent.Children, nodes[descendant.ID])
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"tree":  root,
+	})
+}
+
+func MoveTask(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+	id := task.ID
+	validate := validator.New()
+
+	moveTask := new(models.MoveTask)
+	if err := c.BodyParser(&moveTask); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(moveTask); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	update := bson.M{"$set": bson.M{"updated_at": time.Now().Unix()}, "$unset": bson.M{"parent_id": ""}, "$inc": bson.M{"version": 1}}
+
+	var descendants []models.Task
+	var projectID primitive.ObjectID
+
+	if moveTask.ParentID != nil {
+		parentID, _ := primitive.ObjectIDFromHex(*moveTask.ParentID)
+
+		// Both tasks have to belong to the same owner
+		parent, err := utils.TaskAccess(c.Context(), db, user.ID, parentID, models.RoleEditor)
+		if err != nil || parent.UserId != task.UserId {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Parent task not found",
+			})
+		}
+
+		// A task cannot end up below itself
+		descendants, err = taskDescendants(c.Context(), db, task.UserId, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+
+		if parentID == id || containsTask(descendants, parentID) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "A task cannot be moved below itself or one of its subtasks",
+			})
+		}
+
+		update = bson.M{"$set": bson.M{"parent_id": parentID, "updated_at": time.Now().Unix()}, "$inc": bson.M{"version": 1}}
+		if !parent.ProjectId.IsZero() {
+			projectID = parent.ProjectId
+		}
+	}
+
+	// A task deleted since it was loaded stays in the trash
+	res, err := collection.UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": task.UserId, "deleted_at": nil}, update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task not found",
+		})
+	}
+
+	// Moving below a task of another project takes the whole subtree along
+	if !projectID.IsZero() {
+		ids := append(taskIDs(descendants), id)
+		if _, err := collection.UpdateMany(c.Context(), bson.M{"_id": bson.M{"$in": ids}, "project_id": bson.M{"$ne": projectID}}, bson.M{"$set": bson.M{"project_id": projectID}, "$inc": bson.M{"version": 1}}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+	}
+
+	moved := []models.Task{*task}
+	if !projectID.IsZero() {
+		moved = append(moved, descendants...)
+	}
+	publishTaskChanges(c.Context(), db, models.EventTaskUpdated, user.ID, moved)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Task moved successfully",
+	})
+}
+
+// taskDescendants func to load every task below the given one, at any depth.
+func taskDescendants(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID) ([]models.Task, error) {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": id, "user_id": userID}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":                    collection.Name(),
+			"startWith":               "$_id",
+			"connectFromField":        "_id",
+			"connectToField":          "parent_id",
+			"as":                      "descendants",
+			"restrictSearchWithMatch": bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": false}},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		Descendants []models.Task `bson:"descendants"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return []models.Task{}, nil
+	}
+
+	return results[0].Descendants, nil
+}
+
+// taskProgress func to count the direct subtasks of a task and how many are
+// completed. Tasks without subtasks have no progress.
+func taskProgress(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (*models.TaskProgress, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"parent_id": id, "deleted_at": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"total": bson.M{"$sum": 1},
+			"done":  bson.M{"$sum": bson.M{"$cond": bson.A{"$completed", 1, 0}}},
+		}}},
+	}
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.TaskProgress
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	return &results[0], nil
+}
+
+func containsTask(tasks []models.Task, id primitive.ObjectID) bool {
+	for _, task := range tasks {
+		if task.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func taskIDs(tasks []models.Task) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+
+	return ids
+}
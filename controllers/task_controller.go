@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -30,24 +34,147 @@ func CreateTask(c *fiber.Ctx) error {
 
 	if err := validate.Struct(createTask); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":	true,
-			"message":	err.Error(),
+			"error":   true,
+			"message": err.Error(),
 		})
 	}
 
+	db := c.Locals("db").(*mongo.Database)
+
+	task, err := newTask(c.Context(), db, user, createTask, metadataCreate)
+	if err != nil {
+		return taskRequestError(c, err)
+	}
+
+	if err := insertTask(c.Context(), db, user.ID, task); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Task created successfully",
+		"task":    task.ID,
+	})
+}
+
+// insertTask func to write a new task, record its creation and publish it to
+// the webhooks of its owner.
+func insertTask(ctx context.Context, db *mongo.Database, actorID primitive.ObjectID, task *models.Task) error {
+	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).InsertOne(ctx, task)
+	if err != nil {
+		return err
+	}
+
+	task.ID = res.InsertedID.(primitive.ObjectID)
+	if err := recordRevision(ctx, db, actorID, task.ID, models.RevisionCreate, nil, utils.TaskStateOf(task), nil); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", task.ID.Hex(), err)
+	}
+
+	publishTaskEvent(db, models.EventTaskCreated, actorID, task, nil)
+
+	return nil
+}
+
+// newTask func to build a task from a create request, placing it under its
+// parent, in the requested project or in the Inbox of the user. Problems with
+// the request are returned as *fiber.Error or utils.FieldErrors.
+func newTask(ctx context.Context, db *mongo.Database, user *models.User, createTask *models.CreateTask, check metadataCheck) (*models.Task, error) {
+	if err := utils.CheckTaskDates(createTask.StartAt, createTask.DueAt); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if createTask.Recurrence != "" {
+		if _, err := utils.ParseRRule(createTask.Recurrence); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
 	task := new(models.Task)
+	task.UserId = user.ID
+
+	if createTask.ParentID != "" {
+		parentID, _ := primitive.ObjectIDFromHex(createTask.ParentID)
+		parent, err := utils.TaskAccess(ctx, db, user.ID, parentID, models.RoleEditor)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Parent task not found")
+		}
+		task.ParentId = &parentID
+		task.UserId = parent.UserId
+
+		// Subtasks live in the project of their parent
+		if !parent.ProjectId.IsZero() {
+			if createTask.ProjectID != "" && createTask.ProjectID != parent.ProjectId.Hex() {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Subtasks belong to the project of their parent task")
+			}
+			task.ProjectId = parent.ProjectId
+		}
+	}
+
+	if createTask.ProjectID != "" && task.ProjectId.IsZero() {
+		projectID, _ := primitive.ObjectIDFromHex(createTask.ProjectID)
+		project, err := activeProject(ctx, db, user.ID, projectID)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if task.ParentId != nil && project.UserId != task.UserId {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Subtasks belong to the project of their parent task")
+		}
+		task.ProjectId = projectID
+		task.UserId = project.UserId
+	}
+
+	if task.ProjectId.IsZero() && task.UserId == user.ID {
+		inbox, err := userInbox(ctx, db, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		task.ProjectId = inbox.ID
+	}
+
+	if len(createTask.LabelIDs) > 0 {
+		labelIDs, err := userLabelIDs(ctx, db, task.UserId, createTask.LabelIDs)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		task.Labels = labelIDs
+	}
+
+	metadata, err := checkTaskMetadata(ctx, db, task.UserId, createTask.Metadata, nil, check)
+	if err != nil {
+		return nil, err
+	}
 
 	timestamp := time.Now().Unix()
 
-	task.UserId = user.ID
+	task.CreatedBy = &user.ID
 	task.Title = createTask.Title
 	task.Completed = createTask.Completed
-	task.Metadata = createTask.Metadata
+	task.Metadata = metadata
+	task.SearchText = utils.TaskSearchText(metadata)
+	task.StartAt = createTask.StartAt
+	task.DueAt = createTask.DueAt
+	task.TimeZone = createTask.TimeZone
+	task.Version = 1
 	task.CreatedAt = timestamp
 	task.UpdatedAt = timestamp
+	if createTask.Recurrence != "" {
+		seriesStart := utils.RecurrenceAnchor(task)
+		task.Recurrence = createTask.Recurrence
+		task.SeriesStart = &seriesStart
+	}
+
+	return task, nil
+}
 
+func GetTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
 	db := c.Locals("db").(*mongo.Database)
-	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).InsertOne(c.Context(), task)
+
+	// Metadata filters and sorting follow the custom fields of the user
+	fields, err := userCustomFields(c.Context(), db, user.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -55,22 +182,48 @@ func CreateTask(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"error":   false,
-		"message": "Task created successfully",
-		"task":    res.InsertedID,
-	})
-}
+	query, err := utils.ParseTaskQuery(utils.QueryParams(c), fields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
 
-func GetTasks(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
+	page, err := utils.ParsePageQuery(c, query.Sort)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
 
 	var tasks []models.Task
 
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
 
-	db := c.Locals("db").(*mongo.Database)
-	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(c.Context(), fiber.Map{"user_id": user.ID}, opts)
+	visible, err := visibleTasksFilter(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	filter := bson.M{"$and": bson.A{visible, query.Filter}}
+	opts := options.Find().
+		SetSort(page.SortDocument()).
+		SetLimit(int64(page.Limit + 1))
+
+	total, err := collection.CountDocuments(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	cursor, err := collection.Find(c.Context(), bson.M{"$and": bson.A{filter, page.CursorFilter()}}, opts)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -85,123 +238,600 @@ func GetTasks(c *fiber.Ctx) error {
 		})
 	}
 
-	var tasksResponse []models.GetTask
+	hasMore := len(tasks) > page.Limit
+	if hasMore {
+		tasks = tasks[:page.Limit]
+	}
+
+	// Pages read backwards come out in reverse, flip them back to the requested order.
+	if page.Before != nil {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	}
+
+	pagination := models.Pagination{Limit: page.Limit, Total: total}
+	if len(tasks) > 0 {
+		first, last := tasks[0], tasks[len(tasks)-1]
+
+		if hasMore || page.Before != nil {
+			pagination.Next = utils.PageLink(c, page.Limit, "after", utils.NewCursor(last, last.ID, page.Sort))
+		}
+		if page.After != nil || (page.Before != nil && hasMore) {
+			pagination.Prev = utils.PageLink(c, page.Limit, "before", utils.NewCursor(first, first.ID, page.Sort))
+		}
+	}
+
+	tasksResponse := make([]models.GetTask, 0, len(tasks))
 	for _, task := range tasks {
-		tasksResponse = append(tasksResponse, models.GetTask{
-			ID:        task.ID.Hex(),
-			Title:     task.Title,
-			Completed: task.Completed,
-			Metadata:  task.Metadata,
-			CreatedAt: task.CreatedAt,
-			UpdatedAt: task.UpdatedAt,
+		tasksResponse = append(tasksResponse, taskResponse(&task))
+	}
+
+	if err := fillTaskLabels(c.Context(), db, tasks, tasksResponse); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := fillCommentCounts(c.Context(), db, tasks, tasksResponse); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"error": false,
-		"tasks": tasksResponse,
+		"error":      false,
+		"tasks":      tasksResponse,
+		"pagination": pagination,
 	})
 }
 
 func GetTask(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	responses := []models.GetTask{taskResponse(task)}
+	if err := fillTaskLabels(c.Context(), db, []models.Task{*task}, responses); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
 
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err := fillCommentCounts(c.Context(), db, []models.Task{*task}, responses); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	var err error
+	response := responses[0]
+	if response.Progress, err = taskProgress(c.Context(), db, task.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Only tasks the user can open are listed, dependencies made before they
+	// had to share a project may point elsewhere
+	visible, err := visibleTasksFilter(c.Context(), db, user.ID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid task ID",
+			"message": "Internal Server Error",
 		})
 	}
 
-	task := new(models.Task)
+	if len(task.BlockedBy) > 0 {
+		if response.Blocked, err = taskReferences(c.Context(), db, bson.M{"$and": bson.A{visible, bson.M{"_id": bson.M{"$in": task.BlockedBy}}}}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+	}
 
-	db := c.Locals("db").(*mongo.Database)
-	if err := db.Collection(os.Getenv("TASKS_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}).Decode(&task); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	if response.Blocking, err = taskReferences(c.Context(), db, bson.M{"$and": bson.A{visible, bson.M{"blocked_by": task.ID}}}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
-			"message": "Task not found",
+			"message": "Internal Server Error",
 		})
 	}
 
+	c.Set(fiber.HeaderETag, utils.TaskETag(task.Version))
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"error": false,
-		"task":  models.GetTask{
-			ID:        task.ID.Hex(),
-			Title:     task.Title,
-			Completed: task.Completed,
-			Metadata:  task.Metadata,
-			CreatedAt: task.CreatedAt,
-			UpdatedAt: task.UpdatedAt,
-		},
+		"task":  response,
 	})
 }
 
 func UpdateTask(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
 	var taskUpdate map[string]interface{}
 
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
-	if err != nil {
+	if err := json.Unmarshal(c.Body(), &taskUpdate); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid task ID",
+			"message": err.Error(),
 		})
 	}
 
-	if err := json.Unmarshal(c.Body(), &taskUpdate); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	// Clients that send If-Match only overwrite the version they have seen
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if !utils.IfMatch(ifMatch, task.Version) {
+		return preconditionFailed(c)
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	parsedTaskUpdate, err := parseTaskUpdate(c.Context(), db, task, taskUpdate)
+	if err != nil {
+		return taskRequestError(c, err)
+	}
+
+	return saveTaskUpdate(c, task, ifMatch, parsedTaskUpdate)
+}
+
+// PatchTask func to update a task with a merge patch (RFC 7396) or a JSON
+// Patch (RFC 6902). The patched task is checked as a whole and every invalid
+// field is reported.
+func PatchTask(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if !utils.IfMatch(ifMatch, task.Version) {
+		return preconditionFailed(c)
+	}
+
+	var patched interface{}
+
+	switch strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0]) {
+	case "application/merge-patch+json":
+		var patch interface{}
+		if err := json.Unmarshal(c.Body(), &patch); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+		patched = utils.MergePatch(utils.TaskDocument(task), patch)
+	case "application/json-patch+json":
+		var operations []utils.PatchOperation
+		if err := json.Unmarshal(c.Body(), &operations); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+
+		var err error
+		if patched, err = utils.ApplyJSONPatch(utils.TaskDocument(task), operations); err != nil {
+			patchErr := err.(*utils.PatchError)
+
+			// A failed test means the task is not in the state the client expected
+			status := fiber.StatusBadRequest
+			if patchErr.Failed {
+				status = fiber.StatusConflict
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error":     true,
+				"message":   patchErr.Message,
+				"operation": patchErr.Index,
+			})
+		}
+	default:
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
 			"error":   true,
-			"message": err.Error(),
+			"message": "Content-Type must be application/merge-patch+json or application/json-patch+json",
 		})
 	}
 
-	task := new(models.Task)
+	taskUpdate, fieldErrors := utils.TaskPatchUpdate(utils.TaskDocument(task), patched)
+	if len(fieldErrors) > 0 {
+		return taskRequestError(c, fieldErrors)
+	}
+	taskUpdate["updated_at"] = time.Now().Unix()
 
 	db := c.Locals("db").(*mongo.Database)
-	if err := db.Collection(os.Getenv("TASKS_COLLECTION")).FindOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}).Decode(&task); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error":   true,
-			"message": "Task not found",
+	if err := prepareTaskUpdate(c.Context(), db, task, taskUpdate); err != nil {
+		return taskRequestError(c, err)
+	}
+
+	return saveTaskUpdate(c, task, ifMatch, taskUpdate)
+}
+
+// saveTaskUpdate func to write parsed changes to a task and respond with what
+// followed from them. Tasks with open blockers can only be completed with
+// force=true, cascade=true completes the subtasks along with the task.
+func saveTaskUpdate(c *fiber.Ctx, task *models.Task, ifMatch string, parsedTaskUpdate map[string]interface{}) error {
+	user := c.Locals("user").(*models.User)
+
+	db := c.Locals("db").(*mongo.Database)
+
+	result, err := applyTaskUpdate(c.Context(), db, user, task, ifMatch, parsedTaskUpdate, c.QueryBool("force"), c.QueryBool("cascade"))
+	if blocked, ok := err.(*blockedTaskError); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      true,
+			"message":    blocked.Error(),
+			"blocked_by": blocked.blockers,
 		})
 	}
+	if err != nil {
+		return taskRequestError(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, utils.TaskETag(result.task.Version))
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":           false,
+		"message":         "Task updated successfully",
+		"unblocked":       result.unblocked,
+		"next_occurrence": result.nextOccurrence,
+	})
+}
+
+// taskUpdateResult is what followed from an update of a task.
+type taskUpdateResult struct {
+	task           *models.Task
+	unblocked      []models.TaskReference
+	nextOccurrence interface{}
+}
+
+// blockedTaskError is returned when a task with open blockers is completed
+// without force.
+type blockedTaskError struct {
+	blockers []models.TaskReference
+}
+
+func (e *blockedTaskError) Error() string {
+	return "Task is blocked by incomplete tasks"
+}
+
+var errTaskChanged = fiber.NewError(fiber.StatusPreconditionFailed, "Task was changed since it was last read")
+
+// applyTaskUpdate func to write parsed changes to a task in one versioned
+// write and apply what follows from them, such as completing its subtasks or
+// moving its reminders, and publish the changes to the webhooks of its
+// owner. A task that is gone is a 404 *fiber.Error, one that no longer
+// matches ifMatch is errTaskChanged.
+func applyTaskUpdate(ctx context.Context, db *mongo.Database, user *models.User, task *models.Task, ifMatch string, parsedTaskUpdate map[string]interface{}, force, cascade bool) (*taskUpdateResult, error) {
+	id := task.ID
+
+	dueAt := utils.TaskDateAfterUpdate(parsedTaskUpdate, "due_at", task.DueAt)
+
+	completing, _ := parsedTaskUpdate["completed"].(bool)
+
+	if completing && !task.Completed && !force {
+		blockers, err := openBlockers(ctx, db, task)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(blockers) > 0 {
+			return nil, &blockedTaskError{blockers: blockers}
+		}
+	}
 
-	parsedTaskUpdate := utils.UpdateTaskParser(taskUpdate, task.Metadata)
+	updatedTask := new(models.Task)
 
-	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, bson.M{"$set": parsedTaskUpdate})
+	filter := fiber.Map{"_id": id, "user_id": task.UserId, "deleted_at": bson.M{"$exists": false}}
+	if utils.IfMatchChecksVersion(ifMatch) {
+		filter["version"] = utils.VersionFilter(task.Version)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := db.Collection(os.Getenv("TASKS_COLLECTION")).FindOneAndUpdate(ctx, filter, bson.M{"$set": parsedTaskUpdate, "$inc": bson.M{"version": 1}}, opts).Decode(updatedTask); err != nil {
+		if err == mongo.ErrNoDocuments && ifMatch != "" {
+			return nil, errTaskChanged
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Task not found")
+		}
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, db, user.ID, id, models.RevisionUpdate, utils.TaskStateOf(task), utils.TaskStateOf(updatedTask), nil); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", id.Hex(), err)
+	}
+
+	publishTaskUpdate(db, user.ID, updatedTask, task)
+
+	result := &taskUpdateResult{task: updatedTask, unblocked: []models.TaskReference{}}
+	var err error
+
+	// Completed tasks need no reminders, the others follow the due date
+	if completing {
+		completedIDs := []primitive.ObjectID{id}
+
+		if cascade {
+			descendants, err := taskDescendants(ctx, db, task.UserId, id)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(descendants) > 0 {
+				if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).UpdateMany(ctx, bson.M{"_id": bson.M{"$in": taskIDs(descendants)}}, bson.M{"$set": bson.M{"completed": true, "updated_at": parsedTaskUpdate["updated_at"]}, "$inc": bson.M{"version": 1}}); err != nil {
+					return nil, err
+				}
+				completedIDs = append(completedIDs, taskIDs(descendants)...)
+
+				if err := recordRevisions(ctx, db, user.ID, models.RevisionUpdate, descendants, func(state *models.TaskState) *models.TaskState {
+					completed := *state
+					completed.Completed = true
+					return &completed
+				}); err != nil {
+					log.Printf("Recording history of the subtasks of task %s failed: %v\n", id.Hex(), err)
+				}
+
+				// Webhooks hear about every subtask that was completed with it
+				for i := range descendants {
+					if descendants[i].Completed {
+						continue
+					}
+					completed := descendants[i]
+					completed.Completed = true
+					completed.Version++
+					completed.UpdatedAt = updatedTask.UpdatedAt
+					publishTaskUpdate(db, user.ID, &completed, &descendants[i])
+				}
+			}
+		}
+
+		if err := cancelTaskReminders(ctx, db, completedIDs...); err != nil {
+			log.Printf("Cancelling reminders of task %s failed: %v\n", id.Hex(), err)
+		}
+
+		if result.unblocked, err = unblockedTasks(ctx, db, completedIDs); err != nil {
+			return nil, err
+		}
+
+		// Completing an occurrence of a recurring task creates the next one
+		if !task.Completed {
+			if result.nextOccurrence, err = createNextOccurrence(ctx, db, user.ID, id); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if _, ok := parsedTaskUpdate["due_at"]; ok {
+			if err := rescheduleTaskReminders(ctx, db, id, dueAt); err != nil {
+				log.Printf("Rescheduling reminders of task %s failed: %v\n", id.Hex(), err)
+			}
+		}
+
+		if reopening, ok := parsedTaskUpdate["completed"].(bool); ok && !reopening && task.Completed {
+			if err := resumeTaskReminders(ctx, db, id, dueAt); err != nil {
+				log.Printf("Resuming reminders of task %s failed: %v\n", id.Hex(), err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// parseTaskUpdate func to turn an update request into the fields to set on
+// the task, checking its dates and restarting the series of a new rule.
+// Problems with the request are returned as *fiber.Error or utils.FieldErrors.
+func parseTaskUpdate(ctx context.Context, db *mongo.Database, task *models.Task, taskUpdate map[string]interface{}) (map[string]interface{}, error) {
+	// The parser merges into the metadata it is given, the task keeps its own
+	metadata := make(map[string]interface{}, len(task.Metadata))
+	for key, value := range task.Metadata {
+		metadata[key] = value
+	}
+
+	parsedTaskUpdate, err := utils.UpdateTaskParser(taskUpdate, metadata)
+	if fieldErrors, ok := err.(utils.FieldErrors); ok {
+		return nil, fieldErrors
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Internal Server Error",
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	if res.MatchedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	if err := prepareTaskUpdate(ctx, db, task, parsedTaskUpdate); err != nil {
+		return nil, err
+	}
+
+	return parsedTaskUpdate, nil
+}
+
+// prepareTaskUpdate func to check the metadata and dates a task has after an
+// update and restart the series of a new rule.
+func prepareTaskUpdate(ctx context.Context, db *mongo.Database, task *models.Task, parsedTaskUpdate map[string]interface{}) error {
+	if value, ok := parsedTaskUpdate["metadata"]; ok {
+		metadata, _ := value.(map[string]interface{})
+		checked, err := checkTaskMetadata(ctx, db, task.UserId, metadata, task.Metadata, metadataUpdate)
+		if err != nil {
+			return err
+		}
+		parsedTaskUpdate["metadata"] = checked
+		parsedTaskUpdate["search_text"] = utils.TaskSearchText(checked)
+	}
+
+	startAt := utils.TaskDateAfterUpdate(parsedTaskUpdate, "start_at", task.StartAt)
+	dueAt := utils.TaskDateAfterUpdate(parsedTaskUpdate, "due_at", task.DueAt)
+	if err := utils.CheckTaskDates(startAt, dueAt); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// Dates from the API are instants, only CalDAV gives its dates a form
+	for date, form := range map[string]string{"start_at": "start_form", "due_at": "due_form"} {
+		if _, ok := parsedTaskUpdate[date]; ok {
+			if _, ok := parsedTaskUpdate[form]; !ok {
+				parsedTaskUpdate[form] = nil
+			}
+		}
+	}
+
+	// A new rule starts its series at the current date of the task
+	if value, ok := parsedTaskUpdate["recurrence"]; ok {
+		parsedTaskUpdate["series_start"] = nil
+		if value != nil {
+			parsedTaskUpdate["series_start"] = utils.RecurrenceAnchor(&models.Task{StartAt: startAt, DueAt: dueAt, CreatedAt: task.CreatedAt})
+		}
+	}
+
+	return nil
+}
+
+func DeleteTask(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	// Subtasks are either deleted along with the task or handed to its parent
+	children := c.Query("children", "orphan")
+	if children != "orphan" && children != "cascade" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   true,
-			"message": "Task not found",
+			"message": "children must be orphan or cascade",
 		})
 	}
 
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if !utils.IfMatch(ifMatch, task.Version) {
+		return preconditionFailed(c)
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	purgeAt, err := trashTask(c.Context(), db, user, task, ifMatch, children == "cascade")
+	if err != nil {
+		return taskRequestError(c, err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"error":   false,
-		"message": "Task updated successfully",
+		"error":    false,
+		"message":  "Task moved to trash",
+		"purge_at": purgeAt.Unix(),
 	})
 }
 
-func DeleteTask(c *fiber.Ctx) error {
-	user := c.Locals("user").(*models.User)
+// trashTask func to move a task to the trash, with its subtasks when cascade
+// is set or else handing them to its parent. Deleted tasks go to the trash and
+// are purged by a TTL index on purge_at.
+func trashTask(ctx context.Context, db *mongo.Database, user *models.User, task *models.Task, ifMatch string, cascade bool) (time.Time, error) {
+	id := task.ID
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	descendants, err := taskDescendants(ctx, db, task.UserId, id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	purgeAt := now.Add(trashRetention())
+	trash := bson.M{"deleted_at": now.Unix(), "purge_at": purgeAt, "updated_at": now.Unix()}
+
+	filter := fiber.Map{"_id": id, "user_id": task.UserId, "deleted_at": bson.M{"$exists": false}}
+	if utils.IfMatchChecksVersion(ifMatch) {
+		filter["version"] = utils.VersionFilter(task.Version)
+	}
 
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	res, err := collection.UpdateOne(ctx, filter, bson.M{"$set": trash, "$inc": bson.M{"version": 1}})
 	if err != nil {
+		return time.Time{}, err
+	}
+
+	if res.MatchedCount == 0 && ifMatch != "" {
+		return time.Time{}, errTaskChanged
+	}
+
+	if res.MatchedCount == 0 {
+		return time.Time{}, fiber.NewError(fiber.StatusNotFound, "Task not found")
+	}
+
+	deletedIDs := []primitive.ObjectID{id}
+
+	if cascade && len(descendants) > 0 {
+		trash["trashed_with"] = id
+		_, err = collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": taskIDs(descendants)}}, bson.M{"$set": trash, "$inc": bson.M{"version": 1}})
+		deletedIDs = append(deletedIDs, taskIDs(descendants)...)
+	} else if task.ParentId != nil {
+		_, err = collection.UpdateMany(ctx, bson.M{"parent_id": id}, bson.M{"$set": bson.M{"parent_id": task.ParentId, "updated_at": now.Unix()}, "$inc": bson.M{"version": 1}})
+	} else {
+		_, err = collection.UpdateMany(ctx, bson.M{"parent_id": id}, bson.M{"$unset": bson.M{"parent_id": ""}, "$set": bson.M{"updated_at": now.Unix()}, "$inc": bson.M{"version": 1}})
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := detachTrashedTasks(ctx, db, id, deletedIDs); err != nil {
+		return time.Time{}, err
+	}
+
+	deletedTasks := []models.Task{*task}
+	if cascade {
+		deletedTasks = append(deletedTasks, descendants...)
+	}
+	if err := recordRevisions(ctx, db, user.ID, models.RevisionDelete, deletedTasks, func(*models.TaskState) *models.TaskState {
+		return nil
+	}); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", id.Hex(), err)
+	}
+
+	deletedAt := now.Unix()
+	for i := range deletedTasks {
+		deleted := deletedTasks[i]
+		deleted.DeletedAt = &deletedAt
+		deleted.PurgeAt = &purgeAt
+		deleted.Version++
+		deleted.UpdatedAt = deletedAt
+		publishTaskEvent(db, models.EventTaskDeleted, user.ID, &deleted, nil)
+	}
+
+	if err := setTaskDataPurge(ctx, db, &purgeAt, deletedIDs...); err != nil {
+		log.Printf("Scheduling the purge of the data of task %s failed: %v\n", id.Hex(), err)
+	}
+
+	return purgeAt, nil
+}
+
+func SearchTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	search := utils.ParseSearchQuery(c.Query("q"))
+	if search.IsEmpty() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Search query q is required",
+		})
+	}
+
+	limit := c.QueryInt("limit", utils.DefaultPageLimit)
+	if limit < 1 || limit > utils.MaxPageLimit {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid task ID",
+			"message": "limit must be a number between 1 and " + strconv.Itoa(utils.MaxPageLimit),
 		})
 	}
 
+	var results []struct {
+		models.Task `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+
 	db := c.Locals("db").(*mongo.Database)
-	res, err := db.Collection(os.Getenv("TASKS_COLLECTION")).DeleteOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID})
+
+	visible, err := visibleTasksFilter(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(c.Context(), bson.M{"$and": bson.A{visible, bson.M{"$text": bson.M{"$search": c.Query("q")}}}}, opts)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -209,15 +839,145 @@ func DeleteTask(c *fiber.Ctx) error {
 		})
 	}
 
-	if res.DeletedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	if err := cursor.All(c.Context(), &results); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
-			"message": "Task not found",
+			"message": "Internal Server Error",
+		})
+	}
+
+	tasks := make([]models.Task, 0, len(results))
+	responses := make([]models.GetTask, 0, len(results))
+	for _, result := range results {
+		tasks = append(tasks, result.Task)
+		responses = append(responses, taskResponse(&result.Task))
+	}
+
+	if err := fillTaskLabels(c.Context(), db, tasks, responses); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	tasksResponse := make([]models.SearchTask, 0, len(results))
+	for i, result := range results {
+		highlights := map[string]string{}
+
+		if snippet, ok := search.Highlight(result.Title); ok {
+			highlights["title"] = snippet
+		}
+		for key, value := range result.Metadata {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if snippet, ok := search.Highlight(text); ok {
+				highlights["metadata."+key] = snippet
+			}
+		}
+
+		tasksResponse = append(tasksResponse, models.SearchTask{
+			GetTask:    responses[i],
+			Score:      result.Score,
+			Highlights: highlights,
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"error":   false,
-		"message": "Task deleted successfully",
+		"error": false,
+		"tasks": tasksResponse,
+	})
+}
+
+// visibleTasksFilter func to match the tasks of the user along with the tasks
+// of the projects shared with them, leaving out the ones in the trash.
+func visibleTasksFilter(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (bson.M, error) {
+	accessible, err := accessibleTasksFilter(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"$and": bson.A{accessible, bson.M{"deleted_at": bson.M{"$exists": false}}}}, nil
+}
+
+func accessibleTasksFilter(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (bson.M, error) {
+	shared, err := utils.SharedProjectIDs(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(shared) == 0 {
+		return bson.M{"user_id": userID}, nil
+	}
+
+	return bson.M{"$or": bson.A{bson.M{"user_id": userID}, bson.M{"project_id": bson.M{"$in": shared}}}}, nil
+}
+
+func taskResponse(task *models.Task) models.GetTask {
+	var parentID *string
+	if task.ParentId != nil {
+		hex := task.ParentId.Hex()
+		parentID = &hex
+	}
+
+	var projectID string
+	if !task.ProjectId.IsZero() {
+		projectID = task.ProjectId.Hex()
+	}
+
+	var purgeAt *int64
+	if task.PurgeAt != nil {
+		timestamp := task.PurgeAt.Unix()
+		purgeAt = &timestamp
+	}
+
+	return models.GetTask{
+		ID:         task.ID.Hex(),
+		Title:      task.Title,
+		Completed:  task.Completed,
+		Metadata:   task.Metadata,
+		StartAt:    task.StartAt,
+		DueAt:      task.DueAt,
+		TimeZone:   task.TimeZone,
+		ParentID:   parentID,
+		ProjectID:  projectID,
+		Recurrence: task.Recurrence,
+		Labels:     []models.GetLabel{},
+		Version:    task.Version,
+		DeletedAt:  task.DeletedAt,
+		PurgeAt:    purgeAt,
+		CreatedAt:  task.CreatedAt,
+		UpdatedAt:  task.UpdatedAt,
+	}
+}
+
+// taskRequestError func to respond to an error from building or changing a
+// task. *fiber.Error and utils.FieldErrors are problems with the request,
+// anything else is an internal error.
+func taskRequestError(c *fiber.Ctx, err error) error {
+	switch e := err.(type) {
+	case utils.FieldErrors:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": e.Error(),
+			"fields":  e,
+		})
+	case *fiber.Error:
+		return c.Status(e.Code).JSON(fiber.Map{
+			"error":   true,
+			"message": e.Message,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   true,
+		"message": "Internal Server Error",
 	})
 }
+
+// preconditionFailed func to answer a write whose If-Match no longer matches
+// the task.
+func preconditionFailed(c *fiber.Ctx) error {
+	return taskRequestError(c, errTaskChanged)
+}
@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const maxImportRows = 1000
+
+// ExportTasks func to download every visible task matching the filters of
+// the task listing as csv, json, todotxt or markdown.
+func ExportTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	format := c.Query("format")
+	extension, ok := utils.TaskExportFormats[format]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "format must be one of csv, json, todotxt or markdown",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	fields, err := userCustomFields(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	params := utils.QueryParams(c)
+	delete(params, "format")
+
+	query, err := utils.ParseTaskQuery(params, fields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	visible, err := visibleTasksFilter(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Oldest first unless asked otherwise, so an import recreates them in order
+	sort := bson.D{{Key: "created_at", Value: 1}}
+	if _, ok := params["sort"]; ok {
+		sort = bson.D{}
+		for _, field := range query.Sort {
+			sort = append(sort, bson.E{Key: field.Field, Value: field.Direction})
+		}
+	}
+	sort = append(sort, bson.E{Key: "_id", Value: 1})
+
+	var tasks []models.Task
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(c.Context(), bson.M{"$and": bson.A{visible, query.Filter}}, options.Find().SetSort(sort))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	portable := make([]models.PortableTask, 0, len(tasks))
+	for _, task := range tasks {
+		portable = append(portable, utils.PortableTaskOf(&task))
+	}
+
+	var buffer bytes.Buffer
+	if err := utils.WriteTasks(&buffer, format, portable); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	c.Attachment("tasks" + extension)
+
+	return c.Status(fiber.StatusOK).Send(buffer.Bytes())
+}
+
+// ImportTasks func to create tasks from an uploaded export. Tasks the user
+// already has, with the same title and creation time, are skipped, and so are
+// repeats within the file. Tasks without a creation time are always created. With dry_run=true nothing is written and the report
+// shows what an import would do.
+func ImportTasks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Imports must be uploaded as multipart/form-data",
+		})
+	}
+
+	projectID := c.Query("project_id")
+	if _, err := primitive.ObjectIDFromHex(projectID); projectID != "" && err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "project_id must be a project ID",
+		})
+	}
+	dryRun := c.QueryBool("dry_run")
+
+	// Large bodies arrive as a stream, small ones are already read
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	part, err := attachmentPart(multipart.NewReader(body, boundary))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+	defer part.Close()
+
+	// The format is taken from the file name unless it is given
+	format := c.Query("format")
+	if format == "" {
+		for name, extension := range utils.TaskExportFormats {
+			if filepath.Ext(part.FileName()) == extension {
+				format = name
+			}
+		}
+	}
+	if _, ok := utils.TaskExportFormats[format]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "format must be one of csv, json, todotxt or markdown",
+		})
+	}
+
+	rows, err := utils.ReadTasks(utils.SizeLimitReader(part, utils.MaxImportFileSize), format)
+	if err == utils.ErrAttachmentTooLarge {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":   true,
+			"message": "Import is too large, max " + strconv.Itoa(utils.MaxImportFileSize) + " bytes allowed",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if len(rows) > maxImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "At most " + strconv.Itoa(maxImportRows) + " tasks can be imported at once",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	existing, err := importedTaskKeys(c.Context(), db, user.ID, rows)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	results := make([]models.ImportResult, 0, len(rows))
+	tasks := []interface{}{}
+	created := []*models.Task{}
+
+	for _, row := range rows {
+		result := models.ImportResult{Row: row.Row}
+		if row.Err != nil {
+			result.Status = "invalid"
+			result.Error = row.Err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Title = row.Task.Title
+
+		key := importKey(row.Task.Title, row.Task.CreatedAt)
+		if row.Task.CreatedAt != 0 && existing[key] {
+			result.Status = "duplicate"
+			results = append(results, result)
+			continue
+		}
+
+		task, err := importedTask(c.Context(), db, user, row.Task, projectID)
+		if err != nil {
+			switch e := err.(type) {
+			case utils.FieldErrors:
+				result.Error = e.Error()
+			case *fiber.Error:
+				result.Error = e.Message
+			default:
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   true,
+					"message": "Internal Server Error",
+				})
+			}
+			result.Status = "invalid"
+			results = append(results, result)
+			continue
+		}
+		existing[key] = true
+
+		result.Status = "ready"
+		if !dryRun {
+			task.ID = primitive.NewObjectID()
+			result.Status = "created"
+			result.ID = task.ID.Hex()
+			tasks = append(tasks, task)
+			created = append(created, task)
+		}
+		results = append(results, result)
+	}
+
+	if len(tasks) > 0 {
+		if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).InsertMany(c.Context(), tasks); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+
+		recordImportRevisions(c.Context(), db, user.ID, created)
+		for _, task := range created {
+			publishTaskEvent(db, models.EventTaskCreated, user.ID, task, nil)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":      false,
+		"dry_run":    dryRun,
+		"created":    counts["created"],
+		"ready":      counts["ready"],
+		"duplicates": counts["duplicate"],
+		"invalid":    counts["invalid"],
+		"rows":       results,
+	})
+}
+
+// importedTask func to build a task from an imported one the way a create
+// request would, keeping its completion and timestamps.
+func importedTask(ctx context.Context, db *mongo.Database, user *models.User, portable *models.PortableTask, projectID string) (*models.Task, error) {
+	task, err := newTask(ctx, db, user, &models.CreateTask{
+		Title:      portable.Title,
+		Completed:  portable.Completed,
+		Metadata:   portable.Metadata,
+		StartAt:    portable.StartAt,
+		DueAt:      portable.DueAt,
+		ProjectID:  projectID,
+		Recurrence: portable.Recurrence,
+	}, metadataCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	if portable.CreatedAt != 0 {
+		task.CreatedAt = portable.CreatedAt
+		task.UpdatedAt = portable.CreatedAt
+	}
+	if portable.UpdatedAt != 0 {
+		task.UpdatedAt = portable.UpdatedAt
+	}
+	if task.SeriesStart != nil {
+		seriesStart := utils.RecurrenceAnchor(task)
+		task.SeriesStart = &seriesStart
+	}
+
+	return task, nil
+}
+
+// importedTaskKeys func to find which of the imported tasks the user already
+// has, by title and creation time.
+func importedTaskKeys(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, rows []utils.ImportRow) (map[string]bool, error) {
+	keys := map[string]bool{}
+
+	createdAt := bson.A{}
+	for _, row := range rows {
+		if row.Task != nil && row.Task.CreatedAt != 0 {
+			createdAt = append(createdAt, row.Task.CreatedAt)
+		}
+	}
+	if len(createdAt) == 0 {
+		return keys, nil
+	}
+
+	visible, err := visibleTasksFilter(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+
+	opts := options.Find().SetProjection(bson.M{"title": 1, "created_at": 1})
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, bson.M{"$and": bson.A{visible, bson.M{"created_at": bson.M{"$in": createdAt}}}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		keys[importKey(task.Title, task.CreatedAt)] = true
+	}
+
+	return keys, nil
+}
+
+func importKey(title string, createdAt int64) string {
+	return strconv.FormatInt(createdAt, 10) + "\x00" + title
+}
+
+func recordImportRevisions(ctx context.Context, db *mongo.Database, actorID primitive.ObjectID, tasks []*models.Task) {
+	revisions := make([]interface{}, 0, len(tasks))
+	for _, task := range tasks {
+		revisions = append(revisions, newRevision(actorID, task.ID, models.RevisionCreate, nil, utils.TaskStateOf(task)))
+	}
+
+	if _, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).InsertMany(ctx, revisions); err != nil {
+		log.Printf("Recording history of imported tasks failed: %v\n", err)
+	}
+}
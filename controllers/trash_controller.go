@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const defaultTrashRetentionDays = 30
+
+func GetTrash(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	limit := c.QueryInt("limit", utils.DefaultPageLimit)
+	if limit < 1 || limit > utils.MaxPageLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "limit must be a number between 1 and " + strconv.Itoa(utils.MaxPageLimit),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	accessible, err := accessibleTasksFilter(c.Context(), db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	// Subtasks deleted along with their parent are restored with it
+	filter := bson.M{"$and": bson.A{accessible, bson.M{"deleted_at": bson.M{"$exists": true}, "trashed_with": bson.M{"$exists": false}}}}
+
+	var tasks []models.Task
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deleted_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(c.Context(), filter, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	tasksResponse := make([]models.GetTask, 0, len(tasks))
+	for _, task := range tasks {
+		tasksResponse = append(tasksResponse, taskResponse(&task))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error": false,
+		"tasks": tasksResponse,
+	})
+}
+
+func RestoreTask(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	task := c.Locals("task").(*models.Task)
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	var tasks []models.Task
+
+	cursor, err := collection.Find(c.Context(), bson.M{"$or": bson.A{bson.M{"_id": task.ID}, bson.M{"trashed_with": task.ID}}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	timestamp := time.Now().Unix()
+	update := bson.M{
+		"$set":   bson.M{"updated_at": timestamp},
+		"$unset": bson.M{"deleted_at": "", "purge_at": "", "trashed_links": "", "trashed_reminders": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	// A task whose parent is gone comes back at the top level
+	if task.ParentId != nil {
+		count, err := collection.CountDocuments(c.Context(), bson.M{"_id": task.ParentId, "deleted_at": bson.M{"$exists": false}})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+		if count == 0 {
+			update["$unset"].(bson.M)["parent_id"] = ""
+		}
+	}
+
+	res, err := collection.UpdateOne(c.Context(), bson.M{"_id": task.ID, "deleted_at": bson.M{"$exists": true}}, update)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Task not found in trash",
+		})
+	}
+
+	if _, err := collection.UpdateMany(c.Context(), bson.M{"trashed_with": task.ID}, bson.M{
+		"$set":   bson.M{"updated_at": timestamp},
+		"$unset": bson.M{"deleted_at": "", "purge_at": "", "trashed_with": ""},
+		"$inc":   bson.M{"version": 1},
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	restoredIDs := taskIDs(tasks)
+	if err := setTaskDataPurge(c.Context(), db, nil, restoredIDs...); err != nil {
+		log.Printf("Cancelling the purge of the data of task %s failed: %v\n", task.ID.Hex(), err)
+	}
+
+	if err := reattachRestoredTask(c.Context(), db, user.ID, task); err != nil {
+		log.Printf("Restoring dependencies and reminders of task %s failed: %v\n", task.ID.Hex(), err)
+	}
+
+	if err := recordRevisions(c.Context(), db, user.ID, models.RevisionRestore, tasks, func(state *models.TaskState) *models.TaskState {
+		return state
+	}); err != nil {
+		log.Printf("Recording history of task %s failed: %v\n", task.ID.Hex(), err)
+	}
+
+	// Restored tasks come back into view the way new ones do
+	publishTaskChanges(c.Context(), db, models.EventTaskCreated, user.ID, tasks)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    false,
+		"message":  "Task restored successfully",
+		"restored": restoredIDs,
+	})
+}
+
+func PurgeTask(c *fiber.Ctx) error {
+	task := c.Locals("task").(*models.Task)
+
+	db := c.Locals("db").(*mongo.Database)
+	ids, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Distinct(c.Context(), "_id", bson.M{"$or": bson.A{bson.M{"_id": task.ID}, bson.M{"trashed_with": task.ID}}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := purgeTasks(c.Context(), db, objectIDs(ids)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Task deleted permanently",
+	})
+}
+
+func EmptyTrash(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	// Only the tasks the user owns, the trash of shared projects is left to
+	// their owners
+	db := c.Locals("db").(*mongo.Database)
+	ids, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Distinct(c.Context(), "_id", bson.M{"user_id": user.ID, "deleted_at": bson.M{"$exists": true}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := purgeTasks(c.Context(), db, objectIDs(ids)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Trash emptied successfully",
+		"deleted": len(ids),
+	})
+}
+
+// purgeTasks func to delete tasks for good along with their comments,
+// attachments and history.
+func purgeTasks(ctx context.Context, db *mongo.Database, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+
+	if err := deleteTaskComments(ctx, db, ids...); err != nil {
+		log.Printf("Deleting comments of purged tasks failed: %v\n", err)
+	}
+
+	if err := deleteTaskAttachments(ctx, db, ids...); err != nil {
+		log.Printf("Deleting attachments of purged tasks failed: %v\n", err)
+	}
+
+	if err := deleteTaskHistory(ctx, db, ids...); err != nil {
+		log.Printf("Deleting history of purged tasks failed: %v\n", err)
+	}
+
+	return nil
+}
+
+// setTaskDataPurge func to give the comments, attachments and history of
+// trashed tasks the purge date of their task, so the TTL indexes remove them
+// together. A nil purgeAt keeps them again after a restore.
+func setTaskDataPurge(ctx context.Context, db *mongo.Database, purgeAt *time.Time, taskIDs ...primitive.ObjectID) error {
+	set := func(field string) bson.M {
+		if purgeAt == nil {
+			return bson.M{"$unset": bson.M{field: ""}}
+		}
+		return bson.M{"$set": bson.M{field: *purgeAt}}
+	}
+
+	filter := bson.M{"task_id": bson.M{"$in": taskIDs}}
+	if _, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).UpdateMany(ctx, filter, set("purge_at")); err != nil {
+		return err
+	}
+
+	if _, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).UpdateMany(ctx, filter, set("purge_at")); err != nil {
+		return err
+	}
+
+	files := db.Collection(os.Getenv("ATTACHMENT_COLLECTION"))
+	fileIDs, err := files.Distinct(ctx, "_id", bson.M{"metadata.task_id": bson.M{"$in": taskIDs}})
+	if err != nil || len(fileIDs) == 0 {
+		return err
+	}
+
+	// Attachments in the trash do not count towards the quota of their
+	// uploader, they go away with their task
+	moved := bson.M{"_id": bson.M{"$in": fileIDs}, "metadata.purge_at": bson.M{"$exists": purgeAt == nil}}
+	sizes, err := attachmentSizes(ctx, db, moved)
+	if err != nil {
+		return err
+	}
+	for userID, size := range sizes {
+		if purgeAt != nil {
+			size = -size
+		}
+		addAttachmentBytes(ctx, db, userID, size)
+	}
+
+	if _, err := files.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": fileIDs}}, set("metadata.purge_at")); err != nil {
+		return err
+	}
+
+	_, err = db.Collection(os.Getenv("ATTACHMENT_BUCKET")+".chunks").UpdateMany(ctx, bson.M{"files_id": bson.M{"$in": fileIDs}}, set("purge_at"))
+	return err
+}
+
+// detachTrashedTasks func to take tasks that were just trashed along with root
+// out of the dependencies of other tasks and cancel their pending reminders.
+// What is taken is first kept on root, so a restore can give it back.
+func detachTrashedTasks(ctx context.Context, db *mongo.Database, rootID primitive.ObjectID, deletedIDs []primitive.ObjectID) error {
+	tasks := db.Collection(os.Getenv("TASKS_COLLECTION"))
+	reminders := db.Collection(os.Getenv("REMINDERS_COLLECTION"))
+
+	var dependents []models.Task
+
+	opts := options.Find().SetProjection(bson.M{"blocked_by": 1})
+	cursor, err := tasks.Find(ctx, bson.M{"blocked_by": bson.M{"$in": deletedIDs}}, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := cursor.All(ctx, &dependents); err != nil {
+		return err
+	}
+
+	deleted := map[primitive.ObjectID]bool{}
+	for _, id := range deletedIDs {
+		deleted[id] = true
+	}
+
+	links := []models.TrashedLink{}
+	for _, dependent := range dependents {
+		for _, blockerID := range dependent.BlockedBy {
+			if deleted[blockerID] {
+				links = append(links, models.TrashedLink{TaskId: dependent.ID, BlockedBy: blockerID})
+			}
+		}
+	}
+
+	reminderIDs, err := reminders.Distinct(ctx, "_id", bson.M{"task_id": bson.M{"$in": deletedIDs}, "status": models.ReminderPending})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tasks.UpdateOne(ctx, bson.M{"_id": rootID}, bson.M{"$set": bson.M{"trashed_links": links, "trashed_reminders": objectIDs(reminderIDs)}}); err != nil {
+		return err
+	}
+
+	// Deleted tasks no longer block anything
+	if len(links) > 0 {
+		if _, err := tasks.UpdateMany(ctx, bson.M{"blocked_by": bson.M{"$in": deletedIDs}}, bson.M{"$pull": bson.M{"blocked_by": bson.M{"$in": deletedIDs}}, "$inc": bson.M{"version": 1}}); err != nil {
+			return err
+		}
+	}
+
+	if len(reminderIDs) > 0 {
+		if _, err := reminders.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": reminderIDs}, "status": models.ReminderPending}, bson.M{"$set": bson.M{"status": models.ReminderCancelled, "updated_at": time.Now().Unix()}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reattachRestoredTask func to give a restored task the dependencies and
+// reminders its delete took away. Links to tasks that are gone, moved to
+// another project or that would now close a cycle are dropped, and so are
+// reminders whose time passed while the task was in the trash.
+func reattachRestoredTask(ctx context.Context, db *mongo.Database, actorID primitive.ObjectID, task *models.Task) error {
+	tasks := db.Collection(os.Getenv("TASKS_COLLECTION"))
+	now := time.Now().Unix()
+
+	for _, link := range task.TrashedLinks {
+		dependent := new(models.Task)
+		if err := tasks.FindOne(ctx, bson.M{"_id": link.TaskId, "user_id": task.UserId, "project_id": task.ProjectId}).Decode(dependent); err == mongo.ErrNoDocuments {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := addTaskBlocker(ctx, db, actorID, dependent, link.BlockedBy); err != nil {
+			return err
+		}
+	}
+
+	if len(task.TrashedReminders) == 0 {
+		return nil
+	}
+
+	_, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": task.TrashedReminders}, "status": models.ReminderCancelled, "remind_at": bson.M{"$gt": now}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"status": models.ReminderPending, "next_attempt_at": "$remind_at", "attempts": 0, "updated_at": now}}}})
+
+	return err
+}
+
+// trashRetention func to read how long deleted tasks stay in the trash.
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = defaultTrashRetentionDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func objectIDs(values []interface{}) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		ids = append(ids, value.(primitive.ObjectID))
+	}
+
+	return ids
+}
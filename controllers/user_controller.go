@@ -71,7 +71,23 @@ func UserSignUp(c *fiber.Ctx) error {
 	}
 
 	// Insert the user
-	if _, err := db.Collection(os.Getenv("USER_COLLECTION")).InsertOne(c.Context(), user); err != nil {
+	res, err := db.Collection(os.Getenv("USER_COLLECTION")).InsertOne(c.Context(), user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":	true,
+			"message":	err.Error(),
+		})
+	}
+
+	// Create the Inbox project, a user without one is taken back so the
+	// sign up can be retried
+	if _, err := userInbox(c.Context(), db, res.InsertedID.(primitive.ObjectID)); err != nil {
+		if _, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).DeleteMany(c.Context(), fiber.Map{"user_id": res.InsertedID}); err != nil {
+			log.Printf("Removing the Inbox of user %s failed: %v\n", res.InsertedID.(primitive.ObjectID).Hex(), err)
+		}
+		if _, err := db.Collection(os.Getenv("USER_COLLECTION")).DeleteOne(c.Context(), fiber.Map{"_id": res.InsertedID}); err != nil {
+			log.Printf("Removing user %s without an Inbox failed: %v\n", res.InsertedID.(primitive.ObjectID).Hex(), err)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":	true,
 			"message":	err.Error(),
@@ -294,6 +310,8 @@ func UploadUserAvatar(c *fiber.Ctx) error {
 
 	log.Printf("Write file to DB was successful. File size: %d KB\n", fileSize/1024)
 
+	publishEvent(db, user.ID, models.EventUserAvatarUpdated, fiber.Map{"user_id": user.ID.Hex(), "avatar": "/api/v1/user/avatar/" + user.ID.Hex()})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"error":	false,
 		"message":	"Avatar uploaded successfully",
@@ -375,6 +393,9 @@ func DeleteUserAvatar(c *fiber.Ctx) error {
 		})
 	}
 
+	// Removing the avatar is an update to none
+	publishEvent(db, user.ID, models.EventUserAvatarUpdated, fiber.Map{"user_id": user.ID.Hex(), "avatar": nil})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"error":	false,
 		"message":	"Avatar deleted successfully",
@@ -0,0 +1,665 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/stream"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const (
+	maxWebhooks                         = 10
+	webhookPublishTimeout               = 10 * time.Second
+	defaultWebhookDeliveryRetentionDays = 30
+)
+
+// CreateWebhook func to register an endpoint for the events the user picked.
+// The signing secret is only shown in this response.
+func CreateWebhook(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	createWebhook := new(models.CreateWebhook)
+	if err := c.BodyParser(&createWebhook); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(createWebhook); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	collection := db.Collection(os.Getenv("WEBHOOKS_COLLECTION"))
+
+	count, err := collection.CountDocuments(c.Context(), bson.M{"user_id": user.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if count >= maxWebhooks {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "A user can have at most " + strconv.Itoa(maxWebhooks) + " webhooks",
+		})
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	now := time.Now().Unix()
+	webhook := new(models.Webhook)
+	webhook.UserId = user.ID
+	webhook.URL = createWebhook.URL
+	webhook.Description = createWebhook.Description
+	webhook.Events = createWebhook.Events
+	webhook.Secret = secret
+	webhook.Active = true
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	res, err := collection.InsertOne(c.Context(), webhook)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"message": "Webhook created successfully",
+		"webhook": res.InsertedID,
+		"secret":  secret,
+	})
+}
+
+func GetWebhooks(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var webhooks []models.Webhook
+
+	db := c.Locals("db").(*mongo.Database)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).Find(c.Context(), bson.M{"user_id": user.ID}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &webhooks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	webhooksResponse := make([]models.GetWebhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		webhooksResponse = append(webhooksResponse, webhookResponse(&webhook))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    false,
+		"webhooks": webhooksResponse,
+	})
+}
+
+func GetWebhook(c *fiber.Ctx) error {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"webhook": webhookResponse(webhook),
+	})
+}
+
+func UpdateWebhook(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	validate := validator.New()
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	updateWebhook := new(models.UpdateWebhook)
+	if err := c.BodyParser(&updateWebhook); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(updateWebhook); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	update := bson.M{"updated_at": time.Now().Unix()}
+	if updateWebhook.URL != nil {
+		update["url"] = *updateWebhook.URL
+	}
+	if updateWebhook.Description != nil {
+		update["description"] = *updateWebhook.Description
+	}
+	if updateWebhook.Events != nil {
+		update["events"] = *updateWebhook.Events
+	}
+	if updateWebhook.Active != nil {
+		update["active"] = *updateWebhook.Active
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, bson.M{"$set": update})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Webhook not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Webhook updated successfully",
+	})
+}
+
+// RotateWebhookSecret func to replace the signing secret of a webhook. The
+// new secret is only shown in this response and signs every delivery from
+// now on, retries of earlier ones included.
+func RotateWebhookSecret(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).UpdateOne(c.Context(), fiber.Map{"_id": id, "user_id": user.ID}, bson.M{"$set": bson.M{"secret": secret, "updated_at": time.Now().Unix()}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Webhook not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Webhook secret rotated successfully",
+		"secret":  secret,
+	})
+}
+
+// DeleteWebhook func to remove a webhook along with its deliveries.
+func DeleteWebhook(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+	res, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).DeleteOne(c.Context(), bson.M{"_id": id, "user_id": user.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if res.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Webhook not found",
+		})
+	}
+
+	if _, err := db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).DeleteMany(c.Context(), bson.M{"webhook_id": id}); err != nil {
+		log.Printf("Deleting deliveries of webhook %s failed: %v\n", id.Hex(), err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":   false,
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries func to list the latest deliveries of a webhook,
+// optionally only those with the given status.
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	limit := c.QueryInt("limit", utils.DefaultPageLimit)
+	if limit < 1 || limit > utils.MaxPageLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "limit must be a number between 1 and " + strconv.Itoa(utils.MaxPageLimit),
+		})
+	}
+
+	filter := bson.M{"webhook_id": webhook.ID}
+	switch status := c.Query("status"); status {
+	case "":
+	case models.DeliveryPending, models.DeliverySending, models.DeliverySucceeded, models.DeliveryFailed:
+		filter["status"] = status
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "status must be pending, sending, succeeded or failed",
+		})
+	}
+
+	var deliveries []models.WebhookDelivery
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"payload": 0, "log": 0})
+
+	db := c.Locals("db").(*mongo.Database)
+	cursor, err := db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).Find(c.Context(), filter, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	if err := cursor.All(c.Context(), &deliveries); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	deliveriesResponse := make([]models.GetWebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		deliveriesResponse = append(deliveriesResponse, deliveryResponse(&delivery))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":      false,
+		"deliveries": deliveriesResponse,
+	})
+}
+
+// GetWebhookDelivery func to show a delivery with its payload and the log of
+// its attempts.
+func GetWebhookDelivery(c *fiber.Ctx) error {
+	delivery, err := findWebhookDelivery(c)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    false,
+		"delivery": deliveryResponse(delivery),
+	})
+}
+
+// RedeliverWebhook func to queue a delivery again. The redelivery is a new
+// delivery with the same payload and event ID, so receivers can tell it
+// apart from a new event.
+func RedeliverWebhook(c *fiber.Ctx) error {
+	delivery, err := findWebhookDelivery(c)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	db := c.Locals("db").(*mongo.Database)
+
+	if err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).FindOne(c.Context(), bson.M{"_id": delivery.WebhookId, "active": true}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "Webhook is disabled",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	redelivery := newDelivery(delivery.WebhookId, delivery.UserId, delivery.EventId, delivery.Event, delivery.Payload)
+	redelivery.RedeliveryOf = &delivery.ID
+
+	res, err := db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).InsertOne(c.Context(), redelivery)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":    false,
+		"message":  "Delivery queued successfully",
+		"delivery": res.InsertedID,
+	})
+}
+
+// publishTaskUpdate func to publish the update of a task, and its completion
+// when the update completed it.
+func publishTaskUpdate(db *mongo.Database, actorID primitive.ObjectID, task *models.Task, previous *models.Task) {
+	publishTaskEvent(db, models.EventTaskUpdated, actorID, task, previous)
+	if task.Completed && !previous.Completed {
+		publishTaskEvent(db, models.EventTaskCompleted, actorID, task, previous)
+	}
+}
+
+// publishTaskChanges func to publish an event for every task a write changed,
+// loading them as they are after it. Updates are published along with the
+// tasks as they were before.
+func publishTaskChanges(ctx context.Context, db *mongo.Database, event string, actorID primitive.ObjectID, previous []models.Task) {
+	if len(previous) == 0 {
+		return
+	}
+
+	var tasks []models.Task
+
+	cursor, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Find(ctx, bson.M{"_id": bson.M{"$in": taskIDs(previous)}})
+	if err == nil {
+		err = cursor.All(ctx, &tasks)
+	}
+	if err != nil {
+		log.Printf("Loading changed tasks for %s events failed: %v\n", event, err)
+		return
+	}
+
+	before := make(map[primitive.ObjectID]*models.Task, len(previous))
+	for i := range previous {
+		before[previous[i].ID] = &previous[i]
+	}
+
+	for i := range tasks {
+		if event == models.EventTaskUpdated {
+			publishTaskUpdate(db, actorID, &tasks[i], before[tasks[i].ID])
+			continue
+		}
+		publishTaskEvent(db, event, actorID, &tasks[i], nil)
+	}
+}
+
+// publishTaskEvent func to queue an event about a task for the webhooks of
+// its owner and put it on the bus of the live task streams. Completions are
+// updates to the streams.
+func publishTaskEvent(db *mongo.Database, event string, actorID primitive.ObjectID, task *models.Task, previous *models.Task) {
+	data := fiber.Map{"actor_id": actorID.Hex(), "task": taskResponse(task)}
+	if previous != nil {
+		data["previous"] = taskResponse(previous)
+	}
+
+	publishEvent(db, task.UserId, event, data)
+
+	if event != models.EventTaskCompleted {
+		stream.Publish(event, *task)
+	}
+}
+
+// publishEvent func to queue an event for every active webhook of the user
+// that picked it. The payload is built right away, the webhooks are looked up
+// and the deliveries written in the background so the request that caused
+// the event does not wait for them.
+func publishEvent(db *mongo.Database, userID primitive.ObjectID, event string, data interface{}) {
+	eventID := primitive.NewObjectID().Hex()
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        eventID,
+		Event:     event,
+		CreatedAt: time.Now().Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Encoding %s event failed: %v\n", event, err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookPublishTimeout)
+		defer cancel()
+
+		if err := queueDeliveries(ctx, db, userID, eventID, event, string(payload)); err != nil {
+			log.Printf("Queueing %s event %s failed: %v\n", event, eventID, err)
+		}
+	}()
+}
+
+func queueDeliveries(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, eventID, event, payload string) error {
+	var webhooks []models.Webhook
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).Find(ctx, bson.M{"user_id": userID, "active": true, "events": event}, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	deliveries := make([]interface{}, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		deliveries = append(deliveries, newDelivery(webhook.ID, userID, eventID, event, payload))
+	}
+
+	_, err = db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).InsertMany(ctx, deliveries)
+	return err
+}
+
+func newDelivery(webhookID, userID primitive.ObjectID, eventID, event, payload string) *models.WebhookDelivery {
+	now := time.Now()
+
+	return &models.WebhookDelivery{
+		WebhookId:     webhookID,
+		UserId:        userID,
+		EventId:       eventID,
+		Event:         event,
+		Payload:       payload,
+		Status:        models.DeliveryPending,
+		Log:           []models.WebhookAttempt{},
+		NextAttemptAt: now.Unix(),
+		CreatedAt:     now.Unix(),
+		UpdatedAt:     now.Unix(),
+		ExpireAt:      now.Add(webhookDeliveryRetention()),
+	}
+}
+
+// webhookDeliveryRetention func to read how long deliveries are kept for
+// inspection.
+func webhookDeliveryRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("WEBHOOK_DELIVERY_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = defaultWebhookDeliveryRetentionDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func newWebhookSecret() (string, error) {
+	token, _, err := utils.NewSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + token, nil
+}
+
+func findWebhook(c *fiber.Ctx) (*models.Webhook, error) {
+	user := c.Locals("user").(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	webhook := new(models.Webhook)
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).FindOne(c.Context(), bson.M{"_id": id, "user_id": user.ID}).Decode(webhook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Webhook not found")
+		}
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func findWebhookDelivery(c *fiber.Ctx) (*models.WebhookDelivery, error) {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params("delivery"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid delivery ID")
+	}
+
+	delivery := new(models.WebhookDelivery)
+	db := c.Locals("db").(*mongo.Database)
+	if err := db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).FindOne(c.Context(), bson.M{"_id": id, "webhook_id": webhook.ID}).Decode(delivery); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Delivery not found")
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// webhookError func to respond to an error from looking up a webhook or one
+// of its deliveries.
+func webhookError(c *fiber.Ctx, err error) error {
+	if e, ok := err.(*fiber.Error); ok {
+		return c.Status(e.Code).JSON(fiber.Map{
+			"error":   true,
+			"message": e.Message,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   true,
+		"message": "Internal Server Error",
+	})
+}
+
+func webhookResponse(webhook *models.Webhook) models.GetWebhook {
+	return models.GetWebhook{
+		ID:          webhook.ID.Hex(),
+		URL:         webhook.URL,
+		Description: webhook.Description,
+		Events:      webhook.Events,
+		Active:      webhook.Active,
+		CreatedAt:   webhook.CreatedAt,
+		UpdatedAt:   webhook.UpdatedAt,
+	}
+}
+
+func deliveryResponse(delivery *models.WebhookDelivery) models.GetWebhookDelivery {
+	response := models.GetWebhookDelivery{
+		ID:        delivery.ID.Hex(),
+		WebhookID: delivery.WebhookId.Hex(),
+		EventID:   delivery.EventId,
+		Event:     delivery.Event,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		CreatedAt: delivery.CreatedAt,
+		UpdatedAt: delivery.UpdatedAt,
+	}
+	if delivery.Status == models.DeliveryPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.DeliveredAt != 0 {
+		response.DeliveredAt = &delivery.DeliveredAt
+	}
+	if delivery.RedeliveryOf != nil {
+		response.RedeliveryOf = delivery.RedeliveryOf.Hex()
+	}
+	if delivery.Payload != "" {
+		response.Payload = json.RawMessage(delivery.Payload)
+	}
+	for _, attempt := range delivery.Log {
+		response.Log = append(response.Log, models.GetWebhookAttempt{
+			At:         attempt.At,
+			StatusCode: attempt.StatusCode,
+			Error:      attempt.Error,
+			Duration:   attempt.Duration,
+		})
+	}
+
+	return response
+}
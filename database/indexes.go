@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const taskSearchIndex = "task_search_text"
+
+func CreateIndexes(db *mongo.Database) {
+	log.Println("Creating MongoDB indexes...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A collection has one text index, the one from before search_text
+	// existed covered every string of a task
+	if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Indexes().DropOne(ctx, "task_search"); err != nil && !indexNotFound(err) {
+		log.Fatal(err)
+	}
+
+	if _, err := db.Collection(os.Getenv("TASKS_COLLECTION")).Indexes().CreateMany(ctx, taskIndexes()); err != nil {
+		log.Fatal(err)
+	}
+
+	// Label names are unique per user
+	labels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	if _, err := db.Collection(os.Getenv("LABELS_COLLECTION")).Indexes().CreateMany(ctx, labels); err != nil {
+		log.Fatal(err)
+	}
+
+	// Custom field names are unique per user, they are the metadata keys
+	fields := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	if _, err := db.Collection(os.Getenv("FIELDS_COLLECTION")).Indexes().CreateMany(ctx, fields); err != nil {
+		log.Fatal(err)
+	}
+
+	// Every user has a single Inbox
+	projects := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "archived", Value: 1}, {Key: "name", Value: 1}}},
+		{Keys: bson.D{{Key: "members.user_id", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"inbox": true}),
+		},
+	}
+
+	if _, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).Indexes().CreateMany(ctx, projects); err != nil {
+		log.Fatal(err)
+	}
+
+	// Comments are read per task in thread order
+	comments := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("COMMENTS_COLLECTION")).Indexes().CreateMany(ctx, comments); err != nil {
+		log.Fatal(err)
+	}
+
+	notifications := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	}
+
+	if _, err := db.Collection(os.Getenv("NOTIFICATIONS_COLLECTION")).Indexes().CreateMany(ctx, notifications); err != nil {
+		log.Fatal(err)
+	}
+
+	// History is read per task, newest revision first
+	history := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("HISTORY_COLLECTION")).Indexes().CreateMany(ctx, history); err != nil {
+		log.Fatal(err)
+	}
+
+	// Attachments are listed per task and summed per uploader for quotas.
+	// Files and chunks of trashed tasks expire with their task.
+	attachments := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "metadata.task_id", Value: 1}}},
+		{Keys: bson.D{{Key: "metadata.user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "metadata.purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("ATTACHMENT_COLLECTION")).Indexes().CreateMany(ctx, attachments); err != nil {
+		log.Fatal(err)
+	}
+
+	chunks := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("ATTACHMENT_BUCKET")+".chunks").Indexes().CreateMany(ctx, chunks); err != nil {
+		log.Fatal(err)
+	}
+
+	// The reminder scheduler claims the oldest due reminder first
+	reminders := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "task_id", Value: 1}}},
+	}
+
+	if _, err := db.Collection(os.Getenv("REMINDERS_COLLECTION")).Indexes().CreateMany(ctx, reminders); err != nil {
+		log.Fatal(err)
+	}
+
+	// Events are published to the active webhooks of a user that picked them
+	webhooks := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "events", Value: 1}}},
+	}
+
+	if _, err := db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).Indexes().CreateMany(ctx, webhooks); err != nil {
+		log.Fatal(err)
+	}
+
+	// The webhook scheduler claims deliveries like reminders, the log of a
+	// webhook lists its latest deliveries and TTL removes them after retention
+	deliveries := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION")).Indexes().CreateMany(ctx, deliveries); err != nil {
+		log.Fatal(err)
+	}
+
+	// Calendar feeds are looked up by the hash of their secret
+	users := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "calendar_feed.token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	}
+
+	if _, err := db.Collection(os.Getenv("USER_COLLECTION")).Indexes().CreateMany(ctx, users); err != nil {
+		log.Fatal(err)
+	}
+
+	// App passwords are looked up by their hash on every CalDAV request
+	appPasswords := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "password_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+
+	if _, err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).Indexes().CreateMany(ctx, appPasswords); err != nil {
+		log.Fatal(err)
+	}
+
+	// Each user claims an idempotency key once, records expire after the
+	// replay window
+	idempotency := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("IDEMPOTENCY_COLLECTION")).Indexes().CreateMany(ctx, idempotency); err != nil {
+		log.Fatal(err)
+	}
+
+	// Sessions resume from the events of their user after the last one they
+	// saw, TTL removes events after retention
+	events := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "task.user_id", Value: 1}, {Key: "order", Value: 1}}},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	if _, err := db.Collection(os.Getenv("EVENTS_COLLECTION")).Indexes().CreateMany(ctx, events); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("MongoDB indexes created!")
+}
+
+// taskIndexes func to list the indexes of the tasks collection.
+func taskIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		// Task listing is keyset paginated on created_at and _id per user
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "due_at", Value: 1}}},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+		{Keys: bson.D{{Key: "blocked_by", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "labels", Value: 1}}},
+		// Listing a project pages like the task list, counting it only needs
+		// the completed flag
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "completed", Value: 1}}},
+		// Tasks of shared projects are listed by project alone
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		// The trash lists the latest deletes first and TTL purges them once
+		// their retention is over
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "deleted_at", Value: -1}}},
+		{Keys: bson.D{{Key: "trashed_with", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		// Each occurrence of a series is only created once, however often the
+		// task before it is completed
+		{
+			Keys: bson.D{{Key: "series_id", Value: 1}, {Key: "occurrence_at", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"occurrence_at": bson.M{"$exists": true}}),
+		},
+		// CalDAV finds tasks by the resource name and UID their client gave them
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "dav_name", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"dav_name": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "ical_uid", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"ical_uid": bson.M{"$exists": true}}),
+		},
+		// Search reads the title and the text of the metadata values, weighted
+		// towards the title. Recurrence rules, time zones and other internal
+		// strings are left out.
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "search_text", Value: "text"}},
+			Options: options.Index().
+				SetName(taskSearchIndex).
+				SetWeights(bson.D{{Key: "title", Value: 10}}),
+		},
+	}
+}
+
+// indexNotFound func to tell whether dropping an index failed because it, or
+// its collection, does not exist.
+func indexNotFound(err error) bool {
+	e, ok := err.(mongo.CommandError)
+	return ok && (e.Code == 26 || e.Code == 27)
+}
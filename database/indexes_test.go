@@ -0,0 +1,81 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+// indexedText func to gather what the text index holds for a document.
+func indexedText(t *testing.T, index mongo.IndexModel, task *models.Task) string {
+	t.Helper()
+
+	raw, err := bson.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := bson.M{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{}
+	for _, key := range index.Keys.(bson.D) {
+		if key.Value != "text" {
+			continue
+		}
+		if key.Key == "$**" {
+			t.Fatal("the text index covers every field")
+		}
+		if text, ok := doc[key.Key].(string); ok {
+			texts = append(texts, text)
+		}
+	}
+
+	return strings.ToLower(strings.Join(texts, " "))
+}
+
+func TestTaskSearchIndexSkipsInternalFields(t *testing.T) {
+	var search *mongo.IndexModel
+	for _, index := range taskIndexes() {
+		if index.Options != nil && index.Options.Name != nil && *index.Options.Name == taskSearchIndex {
+			index := index
+			search = &index
+		}
+	}
+	if search == nil {
+		t.Fatal("no search index")
+	}
+
+	metadata := map[string]interface{}{"note": "buy seeds", "points": float64(3)}
+	task := &models.Task{
+		Title:      "Water the plants",
+		Metadata:   metadata,
+		SearchText: utils.TaskSearchText(metadata),
+		Recurrence: "FREQ=DAILY;INTERVAL=1",
+		TimeZone:   "Europe/Paris",
+		DavName:    "daily.ics",
+		ICalUID:    "europe-daily@example.com",
+	}
+
+	text := indexedText(t, *search, task)
+
+	// A search for daily or europe only matches internal fields, the task is
+	// not returned
+	for _, word := range []string{"daily", "europe", "freq", "note"} {
+		if strings.Contains(text, word) {
+			t.Errorf("indexed text %q contains %q", text, word)
+		}
+	}
+	for _, word := range []string{"water", "seeds"} {
+		if !strings.Contains(text, word) {
+			t.Errorf("indexed text %q misses %q", text, word)
+		}
+	}
+}
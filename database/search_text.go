@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const searchTextBatchSize = 500
+
+// BackfillSearchText func to give the tasks from before search_text existed
+// the text of their metadata values, so search finds them by it.
+func BackfillSearchText(db *mongo.Database) {
+	ctx := context.Background()
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	opts := options.Find().SetProjection(bson.M{"metadata": 1})
+	cursor, err := collection.Find(ctx, bson.M{"search_text": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		log.Printf("Backfilling task search text failed: %v\n", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	writes := []mongo.WriteModel{}
+	filled := 0
+	flush := func() bool {
+		if len(writes) == 0 {
+			return true
+		}
+		if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			log.Printf("Backfilling task search text failed: %v\n", err)
+			return false
+		}
+		filled += len(writes)
+		writes = writes[:0]
+		return true
+	}
+
+	for cursor.Next(ctx) {
+		task := new(models.Task)
+		if err := cursor.Decode(task); err != nil {
+			log.Printf("Decoding a task for its search text failed: %v\n", err)
+			continue
+		}
+
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": task.ID, "search_text": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": bson.M{"search_text": utils.TaskSearchText(task.Metadata)}}))
+
+		if len(writes) == searchTextBatchSize && !flush() {
+			return
+		}
+	}
+
+	if !flush() {
+		return
+	}
+
+	if filled > 0 {
+		log.Printf("Task search text backfilled for %d tasks\n", filled)
+	}
+}
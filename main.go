@@ -1,17 +1,35 @@
 package main
 
 import (
+	"context"
+	"log"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	_ "github.com/joho/godotenv/autoload"
 
+	"github.com/roshanpaturkar/go-tasks/controllers"
 	"github.com/roshanpaturkar/go-tasks/database"
 	"github.com/roshanpaturkar/go-tasks/middleware"
+	"github.com/roshanpaturkar/go-tasks/notifier"
 	"github.com/roshanpaturkar/go-tasks/routes"
+	"github.com/roshanpaturkar/go-tasks/scheduler"
+	"github.com/roshanpaturkar/go-tasks/stream"
 )
 
 func main() {
-	app := fiber.New()
+	// Bodies over the limit are streamed to the handlers instead of being
+	// rejected, attachments read the multipart body themselves. CalDAV needs
+	// the WebDAV methods on top of the default ones.
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		RequestMethods:               append(fiber.DefaultMethods, controllers.MethodPropfind, controllers.MethodReport),
+	})
 	
+	// A panic in a handler answers its request with a 500 instead of taking
+	// the server down
+	app.Use(recover.New())
 	middleware.FiberMiddleware(app)
 
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -20,11 +38,39 @@ func main() {
 
 	// DB Ingester Middleware
 	db := database.MongoClient()
+	database.CreateIndexes(db)
+	database.BackfillSearchText(db)
 	app.Use(middleware.IngestDb(db))
 
+	// Background workers
+	reminders := scheduler.NewReminderScheduler(db, map[string]notifier.Notifier{
+		"email":   notifier.NewSMTPNotifier(),
+		"webhook": notifier.NewWebhookNotifier(),
+	})
+	go reminders.Run(context.Background())
+	go scheduler.NewWebhookScheduler(db).Run(context.Background())
+
+	// Live task events come from a change stream, which needs a replica set,
+	// or else from the in-process bus
+	hub := stream.NewHub(db)
+	if err := hub.WatchTasks(context.Background(), db); err != nil {
+		log.Printf("Task change stream unavailable, using the in-process bus: %v\n", err)
+		hub.ListenBus()
+	}
+	app.Use(middleware.IngestHub(hub))
+
 	// Routes
 	routes.UserRoutes(app)
 	routes.TaskRoutes(app)
+	routes.LabelRoutes(app)
+	routes.CustomFieldRoutes(app)
+	routes.ProjectRoutes(app)
+	routes.NotificationRoutes(app)
+	routes.WebhookRoutes(app)
+	routes.TrashRoutes(app)
+	routes.FeedRoutes(app)
+	routes.CalDAVRoutes(app)
+	routes.StreamRoutes(app)
 
 	app.Listen(":3000")
 }
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+// TaskAccess loads the task of the :id route parameter into the "task" local
+// when the user has at least the given role on it.
+func TaskAccess(role string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*models.User)
+
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid task ID",
+			})
+		}
+
+		db := c.Locals("db").(*mongo.Database)
+
+		task, err := utils.TaskAccess(c.Context(), db, user.ID, id, role)
+		if err != nil {
+			return accessError(c, err, "Task not found")
+		}
+
+		c.Locals("task", task)
+		return c.Next()
+	}
+}
+
+// TrashAccess loads the deleted task of the :id route parameter into the
+// "task" local when the user has at least the given role on it.
+func TrashAccess(role string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*models.User)
+
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid task ID",
+			})
+		}
+
+		db := c.Locals("db").(*mongo.Database)
+
+		task, err := utils.TrashedTaskAccess(c.Context(), db, user.ID, id, role)
+		if err != nil {
+			return accessError(c, err, "Task not found in trash")
+		}
+
+		c.Locals("task", task)
+		return c.Next()
+	}
+}
+
+// ProjectAccess loads the project of the :id route parameter into the
+// "project" local when the user has at least the given role on it.
+func ProjectAccess(role string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*models.User)
+
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid project ID",
+			})
+		}
+
+		db := c.Locals("db").(*mongo.Database)
+
+		project, err := utils.ProjectAccess(c.Context(), db, user.ID, id, role)
+		if err != nil {
+			return accessError(c, err, "Project not found")
+		}
+
+		c.Locals("project", project)
+		return c.Next()
+	}
+}
+
+func accessError(c *fiber.Ctx, err error, notFound string) error {
+	switch err {
+	case mongo.ErrNoDocuments:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": notFound,
+		})
+	case utils.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "You do not have permission to do this",
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   true,
+		"message": "Internal Server Error",
+	})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const appPasswordRealm = `Basic realm="go-tasks", charset="UTF-8"`
+
+// AppPasswordAuth func to authenticate clients that can not send a JWT, such
+// as CalDAV clients, with the email of the user and one of their app
+// passwords over Basic auth.
+func AppPasswordAuth() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		email, password, ok := basicCredentials(c.Get(fiber.HeaderAuthorization))
+		if !ok {
+			return unauthorized(c)
+		}
+
+		db := c.Locals("db").(*mongo.Database)
+
+		appPassword := new(models.AppPassword)
+		if err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).FindOne(c.Context(), bson.M{"password_hash": utils.SecretTokenHash(password)}).Decode(appPassword); err != nil {
+			return unauthorized(c)
+		}
+
+		user := new(models.User)
+		if err := db.Collection(os.Getenv("USER_COLLECTION")).FindOne(c.Context(), bson.M{"_id": appPassword.UserId}).Decode(user); err != nil || !strings.EqualFold(user.Email, email) {
+			return unauthorized(c)
+		}
+
+		if _, err := db.Collection(os.Getenv("APP_PASSWORDS_COLLECTION")).UpdateOne(c.Context(), bson.M{"_id": appPassword.ID}, bson.M{"$set": bson.M{"last_used_at": time.Now().Unix()}}); err != nil {
+			log.Printf("Recording the use of app password %s failed: %v\n", appPassword.ID.Hex(), err)
+		}
+
+		c.Locals("user", user)
+
+		return c.Next()
+	}
+}
+
+func basicCredentials(header string) (string, string, bool) {
+	scheme, encoded, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderWWWAuthenticate, appPasswordRealm)
+
+	return c.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
+}
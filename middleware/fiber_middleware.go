@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"io"
+	"regexp"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -9,7 +12,59 @@ import (
 
 func FiberMiddleware(app *fiber.App) {
 	app.Use(
-		cors.New(),
+		// Browsers only hand these headers to scripts when they are exposed.
+		// OPTIONS without a preflight header is a CalDAV client asking what
+		// the server supports.
+		cors.New(cors.Config{
+			ExposeHeaders: fiber.HeaderETag + ", " + HeaderIdempotentReplayed,
+			Next: func(c *fiber.Ctx) bool {
+				return c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) == ""
+			},
+		}),
 		logger.New(),
+		bodyLimit,
 	)
-}
\ No newline at end of file
+}
+
+// streamedBodyRoutes are the uploads that read their body as a stream and
+// check its size themselves.
+var streamedBodyRoutes = regexp.MustCompile(`^/api/v1/task/(import|[0-9a-fA-F]{24}/attachments)/?$`)
+
+// Request bodies are streamed so uploads can be large, every other body keeps
+// the default limit. It is checked on the bytes read, chunked bodies have no
+// length to check up front.
+func bodyLimit(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodPost && streamedBodyRoutes.MatchString(c.Path()) {
+		return c.Next()
+	}
+
+	if c.Request().Header.ContentLength() > fiber.DefaultBodyLimit {
+		return bodyTooLarge(c)
+	}
+
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		body, err := io.ReadAll(io.LimitReader(stream, fiber.DefaultBodyLimit+1))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Request body could not be read",
+			})
+		}
+		if len(body) > fiber.DefaultBodyLimit {
+			return bodyTooLarge(c)
+		}
+		c.Request().SetBody(body)
+	}
+
+	return c.Next()
+}
+
+func bodyTooLarge(c *fiber.Ctx) error {
+	// The rest of the body is not read, the connection can not be reused
+	c.Set(fiber.HeaderConnection, "close")
+
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error":   true,
+		"message": "Request body too large",
+	})
+}
@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/stream"
+)
+
+func IngestHub(hub *stream.Hub) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		c.Locals("hub", hub)
+		return c.Next()
+	}
+}
@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	defaultIdempotencyWindowHours = 24
+	maxIdempotencyKeyLength       = 255
+
+	// A request that did not answer within this time is taken to have died,
+	// a retry runs it again
+	idempotencyLease = 5 * time.Minute
+
+	// Room for the multipart framing around the largest streamed upload
+	multipartFraming = 1 << 20
+)
+
+var errIdempotentBodyTooLarge = errors.New("request body too large")
+
+// Idempotency replays the first response to a request for retries that send
+// the same Idempotency-Key, so clients can retry a POST after a timeout
+// without doing it twice. Keys are kept per user for IDEMPOTENCY_WINDOW_HOURS.
+// A key reused for a different request gets 422, one whose first request is
+// still running gets 409 until the lease of that request runs out.
+func Idempotency() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": HeaderIdempotencyKey + " must be at most " + strconv.Itoa(maxIdempotencyKeyLength) + " characters",
+			})
+		}
+
+		fingerprint := sha256.New()
+		fingerprint.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+
+		// Streamed uploads are hashed on their way to a temporary file that
+		// the handler then reads, so they are not held in memory
+		if stream := c.Context().RequestBodyStream(); stream != nil {
+			spool, size, err := spoolBody(stream, fingerprint)
+			if spool != nil {
+				defer os.Remove(spool.Name())
+				defer spool.Close()
+			}
+			if err == errIdempotentBodyTooLarge {
+				return bodyTooLarge(c)
+			}
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   true,
+					"message": "Request body could not be read",
+				})
+			}
+			c.Request().SetBodyStream(spool, int(size))
+		} else {
+			fingerprint.Write(c.Body())
+		}
+
+		user := c.Locals("user").(*models.User)
+		db := c.Locals("db").(*mongo.Database)
+		collection := db.Collection(os.Getenv("IDEMPOTENCY_COLLECTION"))
+
+		now := time.Now()
+		record := &models.IdempotencyRecord{
+			UserId:      user.ID,
+			Key:         key,
+			Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+			LockId:      primitive.NewObjectID(),
+			LockedUntil: now.Add(idempotencyLease),
+			CreatedAt:   now.Unix(),
+			ExpireAt:    now.Add(idempotencyWindow()),
+		}
+		filter := bson.M{"user_id": user.ID, "key": key}
+		// Only the request holding the lock answers for the key
+		claim := bson.M{"user_id": user.ID, "key": key, "lock_id": record.LockId}
+
+		// The unique index lets only the first request claim the key
+		_, err := collection.InsertOne(c.Context(), record)
+		if mongo.IsDuplicateKeyError(err) {
+			first := new(models.IdempotencyRecord)
+			if err := collection.FindOne(c.Context(), filter).Decode(first); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   true,
+					"message": "Internal Server Error",
+				})
+			}
+
+			switch {
+			// TTL indexes lag behind, a key past its window is free again
+			case first.ExpireAt.Before(now):
+				if _, err = collection.DeleteOne(c.Context(), bson.M{"_id": first.ID}); err == nil {
+					_, err = collection.InsertOne(c.Context(), record)
+				}
+				// Another retry took the key over first
+				if mongo.IsDuplicateKeyError(err) {
+					taken := new(models.IdempotencyRecord)
+					if err := collection.FindOne(c.Context(), filter).Decode(taken); err != nil {
+						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+							"error":   true,
+							"message": "Internal Server Error",
+						})
+					}
+					return replayResponse(c, taken, record.Fingerprint)
+				}
+			// The first request died before it answered, the retry takes over
+			case first.Status == 0 && first.LockedUntil.Before(now) && first.Fingerprint == record.Fingerprint:
+				var result *mongo.UpdateResult
+				result, err = collection.UpdateOne(c.Context(), bson.M{
+					"_id":          first.ID,
+					"status":       0,
+					"locked_until": bson.M{"$not": bson.M{"$gte": now}},
+				}, bson.M{"$set": bson.M{"lock_id": record.LockId, "locked_until": record.LockedUntil}})
+				// Another retry took over first
+				if err == nil && result.MatchedCount == 0 {
+					return replayResponse(c, first, record.Fingerprint)
+				}
+			default:
+				return replayResponse(c, first, record.Fingerprint)
+			}
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Internal Server Error",
+			})
+		}
+
+		// Failed requests give up the key so the retry runs them again
+		err = c.Next()
+		status := c.Response().StatusCode()
+		if err != nil || status >= fiber.StatusInternalServerError {
+			if _, err := collection.DeleteOne(c.Context(), claim); err != nil {
+				log.Printf("Releasing idempotency key of user %s failed: %v\n", user.ID.Hex(), err)
+			}
+			return err
+		}
+
+		if _, err := collection.UpdateOne(c.Context(), claim, bson.M{"$set": bson.M{
+			"status":       status,
+			"content_type": string(c.Response().Header.ContentType()),
+			"body":         c.Response().Body(),
+		}}); err != nil {
+			log.Printf("Storing the response for an idempotency key of user %s failed: %v\n", user.ID.Hex(), err)
+		}
+
+		return nil
+	}
+}
+
+func replayResponse(c *fiber.Ctx, first *models.IdempotencyRecord, fingerprint string) error {
+	if first.Fingerprint != fingerprint {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   true,
+			"message": HeaderIdempotencyKey + " was already used for a different request",
+		})
+	}
+
+	if first.Status == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "A request with this " + HeaderIdempotencyKey + " is still being processed",
+		})
+	}
+
+	c.Set(HeaderIdempotentReplayed, "true")
+	c.Set(fiber.HeaderContentType, first.ContentType)
+
+	return c.Status(first.Status).Send(first.Body)
+}
+
+// spoolBody func to copy a streamed body to a temporary file, writing it to
+// hash on the way, and rewind the file for the handler. Bodies are taken up
+// to the largest upload a streamed route accepts.
+func spoolBody(stream io.Reader, hash io.Writer) (*os.File, int64, error) {
+	spool, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := maxIdempotentBodySize()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(stream, limit+1))
+	if err != nil {
+		return spool, 0, err
+	}
+	if size > limit {
+		return spool, 0, errIdempotentBodyTooLarge
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return spool, 0, err
+	}
+
+	return spool, size, nil
+}
+
+// maxIdempotentBodySize func to read the size of the largest streamed body,
+// an attachment or an import with its multipart framing.
+func maxIdempotentBodySize() int64 {
+	size := utils.AttachmentMaxFileSize()
+	if size < utils.MaxImportFileSize {
+		size = utils.MaxImportFileSize
+	}
+
+	return size + multipartFraming
+}
+
+// idempotencyWindow func to read how long responses are kept for retries.
+func idempotencyWindow() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_WINDOW_HOURS"))
+	if err != nil || hours < 1 {
+		hours = defaultIdempotencyWindowHours
+	}
+
+	return time.Duration(hours) * time.Hour
+}
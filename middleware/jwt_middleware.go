@@ -32,3 +32,17 @@ func jwtError(c *fiber.Ctx, err error) error {
 		"msg":   err.Error(),
 	})
 }
+
+// TokenFromQuery func to take the JWT from the access_token query parameter
+// when there is no Authorization header. Browsers can not set headers on
+// EventSource and WebSocket connections, Auth and ValidateJwt then check the
+// token as usual.
+func TokenFromQuery() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if token := c.Query("access_token"); token != "" && c.Get(fiber.HeaderAuthorization) == "" {
+			c.Request().Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type CreateAppPassword struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// AppPassword is the model for a password a user creates for one client that
+// can not send a JWT, such as a CalDAV client. Only a hash of the password is
+// stored, it is shown once when it is created.
+type AppPassword struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	UserId       primitive.ObjectID `bson:"user_id"`
+	Name         string             `bson:"name"`
+	PasswordHash string             `bson:"password_hash"`
+	LastUsedAt   *int64             `bson:"last_used_at,omitempty"`
+	CreatedAt    int64              `bson:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AttachmentMetadata struct {
+	TaskId      primitive.ObjectID `bson:"task_id"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	ContentType string             `bson:"content_type"`
+}
+
+// Attachment is the GridFS files document of a task attachment. UserId in the
+// metadata is the uploader, whose quota the file counts against.
+type Attachment struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Filename   string             `bson:"filename"`
+	Length     int64              `bson:"length"`
+	UploadDate time.Time          `bson:"uploadDate"`
+	Metadata   AttachmentMetadata `bson:"metadata"`
+}
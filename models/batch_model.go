@@ -0,0 +1,21 @@
+package models
+
+// BatchOperation is one create, update or delete of a batch. Task holds the
+// fields of a create or update request.
+type BatchOperation struct {
+	Op    string                 `json:"op" validate:"required,oneof=create update delete"`
+	ID    string                 `json:"id" validate:"required_unless=Op create,omitempty,mongodb"`
+	Task  map[string]interface{} `json:"task" validate:"required_unless=Op delete"`
+	Force bool                   `json:"force"`
+}
+
+// BatchTasks is a list of operations, or a listing filter with one action to
+// apply to every task it matches. Atomic batches are applied all or nothing,
+// in a transaction, so they need MongoDB to run as a replica set.
+type BatchTasks struct {
+	Operations []BatchOperation       `json:"operations" validate:"max=500"`
+	Filter     map[string]string      `json:"filter"`
+	Action     string                 `json:"action" validate:"required_with=Filter,omitempty,oneof=update delete"`
+	Update     map[string]interface{} `json:"update" validate:"required_if=Action update"`
+	Atomic     bool                   `json:"atomic"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const NotificationMention = "mention"
+
+type CreateComment struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+type UpdateComment struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// Comment is the model for a task comment. Mentions holds the users the
+// comment mentions that can access the task. Deleted comments keep their place
+// in the thread without their body.
+type Comment struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty"`
+	TaskId    primitive.ObjectID   `bson:"task_id"`
+	UserId    primitive.ObjectID   `bson:"user_id"`
+	Body      string               `bson:"body"`
+	Mentions  []primitive.ObjectID `bson:"mentions,omitempty"`
+	CreatedAt int64                `bson:"created_at"`
+	EditedAt  *int64               `bson:"edited_at,omitempty"`
+	DeletedAt *int64               `bson:"deleted_at,omitempty"`
+}
+
+// Notification is the model for an in-app notification of a user, such as
+// being mentioned in a comment by ActorId.
+type Notification struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"`
+	UserId    primitive.ObjectID  `bson:"user_id"`
+	Type      string              `bson:"type"`
+	ActorId   primitive.ObjectID  `bson:"actor_id"`
+	TaskId    primitive.ObjectID  `bson:"task_id"`
+	CommentId *primitive.ObjectID `bson:"comment_id,omitempty"`
+	Read      bool                `bson:"read"`
+	CreatedAt int64               `bson:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	FieldText        = "text"
+	FieldNumber      = "number"
+	FieldDate        = "date"
+	FieldBool        = "bool"
+	FieldEnum        = "enum"
+	FieldMultiSelect = "multi-select"
+)
+
+type CreateCustomField struct {
+	Name     string      `json:"name" validate:"required,max=64"`
+	Type     string      `json:"type" validate:"required,oneof=text number date bool enum multi-select"`
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default"`
+	Options  []string    `json:"options" validate:"omitempty,max=100,dive,min=1,max=100"`
+}
+
+// UpdateCustomField can not change the name or type of a field, the values
+// tasks already hold would no longer match it. Default is kept raw so null can
+// clear it.
+type UpdateCustomField struct {
+	Required *bool           `json:"required"`
+	Default  json.RawMessage `json:"default"`
+	Options  []string        `json:"options" validate:"omitempty,max=100,dive,min=1,max=100"`
+}
+
+// CustomField is the model for a custom field, which types the metadata key
+// of the same name on every task of its user. Dates are unix timestamps and
+// multi-select values are lists of options.
+type CustomField struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserId    primitive.ObjectID `bson:"user_id"`
+	Name      string             `bson:"name"`
+	Type      string             `bson:"type"`
+	Required  bool               `bson:"required"`
+	Default   interface{}        `bson:"default,omitempty"`
+	Options   []string           `bson:"options,omitempty"`
+	CreatedAt int64              `bson:"created_at"`
+	UpdatedAt int64              `bson:"updated_at"`
+}
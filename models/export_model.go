@@ -0,0 +1,14 @@
+package models
+
+// PortableTask is a task the way it is exported and imported, without the IDs
+// that only mean something inside this database.
+type PortableTask struct {
+	Title      string                 `json:"title"`
+	Completed  bool                   `json:"completed"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	StartAt    *int64                 `json:"start_at,omitempty"`
+	DueAt      *int64                 `json:"due_at,omitempty"`
+	Recurrence string                 `json:"recurrence,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	UpdatedAt  int64                  `json:"updated_at"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// CalendarFeed is the secret calendar feed of a user. Only a hash of the
+// secret is stored, the feed URL is shown once when it is created.
+type CalendarFeed struct {
+	TokenHash string `bson:"token_hash"`
+	CreatedAt int64  `bson:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRevert  = "revert"
+	RevisionRestore = "restore"
+)
+
+// TaskState is the part of a task its history keeps track of.
+type TaskState struct {
+	Title     string                 `bson:"title" json:"title"`
+	Completed bool                   `bson:"completed" json:"completed"`
+	Metadata  map[string]interface{} `bson:"metadata,omitempty" json:"metadata"`
+}
+
+// FieldChange is the before and after value of one tracked field, metadata
+// keys are tracked one by one as metadata.<key>. A nil value means the field
+// did not exist on that side of the change.
+type FieldChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// Revision is the model for one entry of the history of a task. State is the
+// task as it was after the change, or right before it for deletes.
+type Revision struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty"`
+	TaskId       primitive.ObjectID  `bson:"task_id"`
+	ActorId      primitive.ObjectID  `bson:"actor_id"`
+	Action       string              `bson:"action"`
+	Changes      []FieldChange       `bson:"changes"`
+	State        TaskState           `bson:"state"`
+	RevertedFrom *primitive.ObjectID `bson:"reverted_from,omitempty"`
+	CreatedAt    int64               `bson:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyRecord is the model for the first request a user sent with an
+// Idempotency-Key. Fingerprint tells retries from other requests reusing the
+// key, Status stays 0 until the first request has its response. The request
+// holding LockId runs it, a retry takes the lock over once LockedUntil passed
+// without a response. Records are removed by a TTL index on ExpireAt.
+type IdempotencyRecord struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	Key         string             `bson:"key"`
+	Fingerprint string             `bson:"fingerprint"`
+	Status      int                `bson:"status"`
+	ContentType string             `bson:"content_type,omitempty"`
+	Body        []byte             `bson:"body,omitempty"`
+	LockId      primitive.ObjectID `bson:"lock_id"`
+	LockedUntil time.Time          `bson:"locked_until"`
+	CreatedAt   int64              `bson:"created_at"`
+	ExpireAt    time.Time          `bson:"expire_at"`
+}
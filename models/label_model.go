@@ -0,0 +1,31 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type CreateLabel struct {
+	Name        string `json:"name" validate:"required,max=50"`
+	Color       string `json:"color" validate:"omitempty,hexcolor"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+type UpdateLabel struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=50"`
+	Color       *string `json:"color" validate:"omitempty,hexcolor"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
+
+type AssignLabels struct {
+	LabelIDs []string `json:"label_ids" validate:"required,min=1,dive,mongodb"`
+}
+
+// Label is the model for a label, tasks reference labels by ID so renaming a
+// label is reflected on every task at once
+type Label struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	Name        string             `bson:"name"`
+	Color       string             `bson:"color,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	CreatedAt   int64              `bson:"created_at"`
+	UpdatedAt   int64              `bson:"updated_at"`
+}
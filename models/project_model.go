@@ -0,0 +1,60 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Roles a member can have on a shared project, each one includes the
+// permissions of the roles before it
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+type CreateProject struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Color       string `json:"color" validate:"omitempty,hexcolor"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+type UpdateProject struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Color       *string `json:"color" validate:"omitempty,hexcolor"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
+
+type MoveTaskProject struct {
+	ProjectID string `json:"project_id" validate:"required,mongodb"`
+}
+
+type AddMember struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=viewer editor owner"`
+}
+
+type UpdateMember struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor owner"`
+}
+
+type ProjectMember struct {
+	UserId  primitive.ObjectID `bson:"user_id"`
+	Role    string             `bson:"role"`
+	AddedAt int64              `bson:"added_at"`
+}
+
+// Project is the model for a project. Every user has exactly one Inbox
+// project, which takes the tasks that were not put anywhere else. The user who
+// created a project owns it and its tasks, Members are the users it is shared
+// with.
+type Project struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	Name        string             `bson:"name"`
+	Color       string             `bson:"color,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Inbox       bool               `bson:"inbox"`
+	Members     []ProjectMember    `bson:"members,omitempty"`
+	Archived    bool               `bson:"archived"`
+	ArchivedAt  *int64             `bson:"archived_at,omitempty"`
+	CreatedAt   int64              `bson:"created_at"`
+	UpdatedAt   int64              `bson:"updated_at"`
+}
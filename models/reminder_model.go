@@ -0,0 +1,40 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	ReminderPending   = "pending"
+	ReminderFiring    = "firing"
+	ReminderSent      = "sent"
+	ReminderFailed    = "failed"
+	ReminderCancelled = "cancelled"
+)
+
+type CreateReminder struct {
+	RemindAt *int64 `json:"remind_at" validate:"required_without=Offset,excluded_with=Offset,omitempty,gt=0"`
+	Offset   *int64 `json:"offset" validate:"omitempty,gte=0"`
+	Channel  string `json:"channel" validate:"required,oneof=email webhook"`
+	Target   string `json:"target" validate:"required_if=Channel webhook,omitempty,url"`
+}
+
+// Reminder is the model for a task reminder. Offset reminders are relative
+// to the due date of the task and move with it. Reminders cancelled because
+// their task was completed come back when it is reopened.
+type Reminder struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty"`
+	TaskId                primitive.ObjectID `bson:"task_id"`
+	UserId                primitive.ObjectID `bson:"user_id"`
+	RemindAt              int64              `bson:"remind_at"`
+	Offset                *int64             `bson:"offset,omitempty"`
+	Channel               string             `bson:"channel"`
+	Target                string             `bson:"target,omitempty"`
+	Status                string             `bson:"status"`
+	Attempts              int                `bson:"attempts"`
+	NextAttemptAt         int64              `bson:"next_attempt_at"`
+	LockedUntil           int64              `bson:"locked_until,omitempty"`
+	LastError             string             `bson:"last_error,omitempty"`
+	CancelledOnCompletion bool               `bson:"cancelled_on_completion,omitempty"`
+	SentAt                int64              `bson:"sent_at,omitempty"`
+	CreatedAt             int64              `bson:"created_at"`
+	UpdatedAt             int64              `bson:"updated_at"`
+}
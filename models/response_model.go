@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type UserProfileResponse struct {
 	ID        string `json:"id"`
 	FirstName string `json:"first_name"`
@@ -12,10 +14,206 @@ type UserProfileResponse struct {
 }
 
 type GetTask struct {
-	ID        string            `json:"id"`
-	Title     string            `json:"title"`
-	Completed bool              `json:"completed"`
-	Metadata  map[string]string `json:"metadata"`
-	CreatedAt int64             `json:"created_at"`
-	UpdatedAt int64             `json:"updated_at"`
-}
\ No newline at end of file
+	ID         string                 `json:"id"`
+	Title      string                 `json:"title"`
+	Completed  bool                   `json:"completed"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	StartAt    *int64                 `json:"start_at"`
+	DueAt      *int64                 `json:"due_at"`
+	TimeZone   string                 `json:"time_zone,omitempty"`
+	ParentID   *string                `json:"parent_id"`
+	ProjectID  string                 `json:"project_id,omitempty"`
+	Recurrence string                 `json:"recurrence,omitempty"`
+	Labels     []GetLabel             `json:"labels"`
+	Progress   *TaskProgress          `json:"progress,omitempty"`
+	Blocked    []TaskReference        `json:"blocked,omitempty"`
+	Blocking   []TaskReference        `json:"blocking,omitempty"`
+	Comments   int64                  `json:"comments"`
+	Version    int64                  `json:"version"`
+	DeletedAt  *int64                 `json:"deleted_at,omitempty"`
+	PurgeAt    *int64                 `json:"purge_at,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	UpdatedAt  int64                  `json:"updated_at"`
+}
+
+type TaskProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+type TaskReference struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+type TaskTree struct {
+	GetTask
+	Children []*TaskTree `json:"children"`
+}
+
+type Pagination struct {
+	Limit int    `json:"limit"`
+	Total int64  `json:"total"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+type SearchTask struct {
+	GetTask
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type GetReminder struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	RemindAt  int64  `json:"remind_at"`
+	Offset    *int64 `json:"offset,omitempty"`
+	Channel   string `json:"channel"`
+	Target    string `json:"target,omitempty"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	SentAt    int64  `json:"sent_at,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type GetLabel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+	CreatedAt   int64  `json:"created_at,omitempty"`
+	UpdatedAt   int64  `json:"updated_at,omitempty"`
+}
+
+type GetProject struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Color       string        `json:"color,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Inbox       bool          `json:"inbox"`
+	Archived    bool          `json:"archived"`
+	Role        string        `json:"role"`
+	ArchivedAt  *int64        `json:"archived_at,omitempty"`
+	Tasks       *TaskProgress `json:"tasks"`
+	CreatedAt   int64         `json:"created_at"`
+	UpdatedAt   int64         `json:"updated_at"`
+}
+
+type GetMember struct {
+	UserID    string `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	AddedAt   int64  `json:"added_at"`
+}
+
+type GetComment struct {
+	ID        string   `json:"id"`
+	TaskID    string   `json:"task_id"`
+	AuthorID  string   `json:"author_id"`
+	Body      string   `json:"body"`
+	Mentions  []string `json:"mentions"`
+	Deleted   bool     `json:"deleted"`
+	CreatedAt int64    `json:"created_at"`
+	EditedAt  *int64   `json:"edited_at,omitempty"`
+}
+
+type GetNotification struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	ActorID   string `json:"actor_id"`
+	TaskID    string `json:"task_id"`
+	CommentID string `json:"comment_id,omitempty"`
+	Read      bool   `json:"read"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type GetAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	UploadedBy  string `json:"uploaded_by"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type GetRevision struct {
+	ID           string        `json:"id"`
+	Action       string        `json:"action"`
+	ActorID      string        `json:"actor_id"`
+	Changes      []FieldChange `json:"changes"`
+	State        TaskState     `json:"state"`
+	RevertedFrom string        `json:"reverted_from,omitempty"`
+	CreatedAt    int64         `json:"created_at"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type GetCustomField struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	Required  bool        `json:"required"`
+	Default   interface{} `json:"default"`
+	Options   []string    `json:"options,omitempty"`
+	CreatedAt int64       `json:"created_at"`
+	UpdatedAt int64       `json:"updated_at"`
+}
+
+type ImportResult struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type GetAppPassword struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	LastUsedAt *int64 `json:"last_used_at"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type GetWebhook struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	Active      bool     `json:"active"`
+	CreatedAt   int64    `json:"created_at"`
+	UpdatedAt   int64    `json:"updated_at"`
+}
+
+type GetWebhookAttempt struct {
+	At         int64  `json:"at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Duration   int64  `json:"duration"`
+}
+
+type GetWebhookDelivery struct {
+	ID            string              `json:"id"`
+	WebhookID     string              `json:"webhook_id"`
+	EventID       string              `json:"event_id"`
+	Event         string              `json:"event"`
+	Status        string              `json:"status"`
+	Attempts      int                 `json:"attempts"`
+	NextAttemptAt *int64              `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *int64              `json:"delivered_at,omitempty"`
+	RedeliveryOf  string              `json:"redelivery_of,omitempty"`
+	Payload       json.RawMessage     `json:"payload,omitempty"`
+	Log           []GetWebhookAttempt `json:"log,omitempty"`
+	CreatedAt     int64               `json:"created_at"`
+	UpdatedAt     int64               `json:"updated_at"`
+}
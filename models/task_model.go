@@ -1,20 +1,91 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type CreateTask struct {
-	Title	string	`json:"title" validate:"required"`
-	Completed bool `json:"completed"`
-	Metadata map[string]string `json:"metadata"`
+	Title      string                 `json:"title" validate:"required"`
+	Completed  bool                   `json:"completed"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	StartAt    *int64                 `json:"start_at" validate:"omitempty,gt=0"`
+	DueAt      *int64                 `json:"due_at" validate:"omitempty,gt=0"`
+	ParentID   string                 `json:"parent_id" validate:"omitempty,mongodb"`
+	ProjectID  string                 `json:"project_id" validate:"omitempty,mongodb"`
+	TimeZone   string                 `json:"time_zone" validate:"omitempty,timezone"`
+	Recurrence string                 `json:"recurrence"`
+	LabelIDs   []string               `json:"label_ids" validate:"omitempty,dive,mongodb"`
 }
 
-// Task is the model for the task
+// Forms a date of a task can have in iCalendar. Dates without one are UTC
+// date-times, CalDAV clients may also send all-day dates, floating times and
+// times in the time zone of the task, which are written back the same way.
+const (
+	DateFormDate     = "date"
+	DateFormFloating = "floating"
+	DateFormZoned    = "zoned"
+)
+
+type AddDependency struct {
+	BlockedBy string `json:"blocked_by" validate:"required,mongodb"`
+}
+
+type MoveTask struct {
+	ParentID *string `json:"parent_id" validate:"omitempty,mongodb"`
+}
+
+// Task is the model for the task. Recurring tasks repeat by their RRULE from
+// SeriesStart in TimeZone and every occurrence shares the SeriesId of the first
+// one, OccurrenceAt is the occurrence a task was created for. Tasks belong to
+// the owner of their project, CreatedBy is the member who added it.
+// Deleted tasks stay in the trash until PurgeAt, subtasks deleted along with
+// their parent point to it with TrashedWith. The dependencies and pending
+// reminders a delete takes away are kept on the task that was deleted, in
+// TrashedLinks and TrashedReminders, until a restore gives them back. Version
+// goes up with every write and is the ETag of the task, BatchId is the last
+// batch that changed it. SearchText holds the metadata values text search
+// reads, it is rewritten whenever the metadata changes. Tasks created over
+// CalDAV keep the resource name and UID their client gave them, and the form
+// of their dates.
 type Task struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserId    primitive.ObjectID `bson:"user_id,omitempty"`
-	Title     string             `bson:"title,required"`
-	Completed bool               `bson:"completed,default:false"`
-	Metadata  map[string]string  `bson:"metadata,omitempty"`
-	CreatedAt int64              `bson:"created_at"`
-	UpdatedAt int64              `bson:"updated_at"`
-}
\ No newline at end of file
+	ID               primitive.ObjectID     `bson:"_id,omitempty"`
+	UserId           primitive.ObjectID     `bson:"user_id,omitempty"`
+	Title            string                 `bson:"title,required"`
+	Completed        bool                   `bson:"completed,default:false"`
+	Metadata         map[string]interface{} `bson:"metadata,omitempty"`
+	SearchText       string                 `bson:"search_text"`
+	StartAt          *int64                 `bson:"start_at,omitempty"`
+	DueAt            *int64                 `bson:"due_at,omitempty"`
+	StartForm        string                 `bson:"start_form,omitempty"`
+	DueForm          string                 `bson:"due_form,omitempty"`
+	TimeZone         string                 `bson:"time_zone,omitempty"`
+	ParentId         *primitive.ObjectID    `bson:"parent_id,omitempty"`
+	ProjectId        primitive.ObjectID     `bson:"project_id,omitempty"`
+	BlockedBy        []primitive.ObjectID   `bson:"blocked_by,omitempty"`
+	Labels           []primitive.ObjectID   `bson:"labels,omitempty"`
+	Recurrence       string                 `bson:"recurrence,omitempty"`
+	SeriesStart      *int64                 `bson:"series_start,omitempty"`
+	SeriesId         *primitive.ObjectID    `bson:"series_id,omitempty"`
+	OccurrenceAt     *int64                 `bson:"occurrence_at,omitempty"`
+	CreatedBy        *primitive.ObjectID    `bson:"created_by,omitempty"`
+	DavName          string                 `bson:"dav_name,omitempty"`
+	ICalUID          string                 `bson:"ical_uid,omitempty"`
+	Version          int64                  `bson:"version"`
+	BatchId          *primitive.ObjectID    `bson:"batch_id,omitempty"`
+	DeletedAt        *int64                 `bson:"deleted_at,omitempty"`
+	PurgeAt          *time.Time             `bson:"purge_at,omitempty"`
+	TrashedWith      *primitive.ObjectID    `bson:"trashed_with,omitempty"`
+	TrashedLinks     []TrashedLink          `bson:"trashed_links,omitempty"`
+	TrashedReminders []primitive.ObjectID   `bson:"trashed_reminders,omitempty"`
+	CreatedAt        int64                  `bson:"created_at"`
+	UpdatedAt        int64                  `bson:"updated_at"`
+}
+
+// TrashedLink is a dependency a delete removed: TaskId was blocked by the
+// deleted task BlockedBy.
+type TrashedLink struct {
+	TaskId    primitive.ObjectID `bson:"task_id"`
+	BlockedBy primitive.ObjectID `bson:"blocked_by"`
+}
@@ -11,6 +11,7 @@ type User struct {
 	Mobile   string `bson:"mobile,omitempty"`
 	PasswordHash string `bson:"password_hash"`
 	Tokens []string `bson:"tokens"`
+	CalendarFeed *CalendarFeed `bson:"calendar_feed,omitempty"`
 	CreatedAt int64 `bson:"created_at"`
 	UpdatedAt int64 `bson:"updated_at"`
 }
\ No newline at end of file
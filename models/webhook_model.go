@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	EventTaskCreated       = "task.created"
+	EventTaskUpdated       = "task.updated"
+	EventTaskCompleted     = "task.completed"
+	EventTaskDeleted       = "task.deleted"
+	EventUserAvatarUpdated = "user.avatar.updated"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type CreateWebhook struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048"`
+	Description string   `json:"description" validate:"max=200"`
+	Events      []string `json:"events" validate:"required,min=1,unique,dive,oneof=task.created task.updated task.completed task.deleted user.avatar.updated"`
+}
+
+type UpdateWebhook struct {
+	URL         *string   `json:"url" validate:"omitempty,http_url,max=2048"`
+	Description *string   `json:"description" validate:"omitempty,max=200"`
+	Events      *[]string `json:"events" validate:"omitempty,min=1,unique,dive,oneof=task.created task.updated task.completed task.deleted user.avatar.updated"`
+	Active      *bool     `json:"active"`
+}
+
+// Webhook is the model for an endpoint a user wants events posted to. The
+// secret signs every delivery, so unlike passwords it is kept as is.
+type Webhook struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserId      primitive.ObjectID `bson:"user_id"`
+	URL         string             `bson:"url"`
+	Description string             `bson:"description,omitempty"`
+	Events      []string           `bson:"events"`
+	Secret      string             `bson:"secret"`
+	Active      bool               `bson:"active"`
+	CreatedAt   int64              `bson:"created_at"`
+	UpdatedAt   int64              `bson:"updated_at"`
+}
+
+// WebhookEvent is the body of a delivery. Every delivery of the same event,
+// redeliveries included, carries the same ID.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt int64       `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookAttempt is the outcome of one attempt to deliver an event.
+// Responses are not kept, only their status.
+type WebhookAttempt struct {
+	At         int64  `bson:"at"`
+	StatusCode int    `bson:"status_code,omitempty"`
+	Error      string `bson:"error,omitempty"`
+	Duration   int64  `bson:"duration"`
+}
+
+// WebhookDelivery is the model for an event queued for a webhook. Payload is
+// the exact body that is signed and sent. Deliveries are removed by a TTL
+// index on ExpireAt.
+type WebhookDelivery struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty"`
+	WebhookId     primitive.ObjectID  `bson:"webhook_id"`
+	UserId        primitive.ObjectID  `bson:"user_id"`
+	EventId       string              `bson:"event_id"`
+	Event         string              `bson:"event"`
+	Payload       string              `bson:"payload"`
+	Status        string              `bson:"status"`
+	Attempts      int                 `bson:"attempts"`
+	Log           []WebhookAttempt    `bson:"log"`
+	NextAttemptAt int64               `bson:"next_attempt_at"`
+	LockedUntil   int64               `bson:"locked_until,omitempty"`
+	RedeliveryOf  *primitive.ObjectID `bson:"redelivery_of,omitempty"`
+	DeliveredAt   int64               `bson:"delivered_at,omitempty"`
+	CreatedAt     int64               `bson:"created_at"`
+	UpdatedAt     int64               `bson:"updated_at"`
+	ExpireAt      time.Time           `bson:"expire_at"`
+}
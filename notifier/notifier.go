@@ -0,0 +1,19 @@
+package notifier
+
+import "context"
+
+// Notification struct to describe a message for a single recipient. Target is
+// an email address or a webhook URL depending on the channel. ID stays the
+// same when a notification is sent again, so receivers can drop repeats.
+type Notification struct {
+	ID      string
+	Target  string
+	Subject string
+	Body    string
+	Data    map[string]interface{}
+}
+
+// Notifier is implemented by every delivery channel.
+type Notifier interface {
+	Send(ctx context.Context, notification Notification) error
+}
@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier delivers notifications as plain text emails. Leaving
+// SMTP_USERNAME empty skips authentication, which is what local stand-ins
+// like MailHog expect. The ID of the notification makes its Message-ID.
+type SMTPNotifier struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPNotifier() *SMTPNotifier {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+
+	return &SMTPNotifier{
+		Addr:     net.JoinHostPort(host, port),
+		Host:     host,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, notification Notification) error {
+	if n.Host == "" {
+		return errors.New("SMTP is not configured")
+	}
+
+	// Header injection guard, the subject and recipient end up in raw headers
+	if strings.ContainsAny(notification.Target, "\r\n") {
+		return errors.New("Invalid email address")
+	}
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(notification.Subject)
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	headers := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", n.From, notification.Target, subject, time.Now().Format(time.RFC1123Z))
+	if id := strings.NewReplacer("\r", "", "\n", "", "<", "", ">", "").Replace(notification.ID); id != "" {
+		headers += "Message-ID: <" + id + "@" + n.Host + ">\r\n"
+	}
+	message := headers + "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n" + notification.Body + "\r\n"
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.Addr, auth, n.From, []string{notification.Target}, []byte(message))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
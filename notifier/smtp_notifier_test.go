@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one message without authentication and hands back its
+// envelope recipients and data.
+type fakeSMTP struct {
+	listener net.Listener
+	messages chan smtpMessage
+}
+
+type smtpMessage struct {
+	recipients []string
+	data       string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeSMTP{listener: listener, messages: make(chan smtpMessage, 1)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return server
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var message smtpMessage
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO"):
+			message.recipients = append(message.recipients, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			message.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			s.messages <- message
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) notifier() *SMTPNotifier {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return &SMTPNotifier{Addr: s.listener.Addr().String(), Host: host, From: "tasks@example.com"}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := newFakeSMTP(t)
+
+	err := server.notifier().Send(context.Background(), Notification{
+		ID:      "reminder.1700000000",
+		Target:  "user@example.com",
+		Subject: "Reminder: Buy milk\r\nBcc: someone@example.com",
+		Body:    "Reminder for your task: Buy milk",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-server.messages
+	if len(message.recipients) != 1 || message.recipients[0] != "user@example.com" {
+		t.Errorf("recipients = %v", message.recipients)
+	}
+	for _, header := range []string{
+		"From: tasks@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Reminder: Buy milk  Bcc: someone@example.com\r\n",
+		"Message-ID: <reminder.1700000000@127.0.0.1>\r\n",
+	} {
+		if !strings.Contains(message.data, header) {
+			t.Errorf("message lacks %q:\n%s", header, message.data)
+		}
+	}
+	if !strings.Contains(message.data, "\r\n\r\nReminder for your task: Buy milk\r\n") {
+		t.Errorf("message lacks the body:\n%s", message.data)
+	}
+}
+
+func TestSMTPNotifierErrors(t *testing.T) {
+	if err := (&SMTPNotifier{}).Send(context.Background(), Notification{Target: "user@example.com"}); err == nil {
+		t.Error("no error without an SMTP host")
+	}
+
+	n := &SMTPNotifier{Addr: "127.0.0.1:1", Host: "127.0.0.1"}
+	if err := n.Send(context.Background(), Notification{Target: "user@example.com\r\nBcc: someone@example.com"}); err == nil {
+		t.Error("no error for a target with a line break")
+	}
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+// WebhookNotifier delivers notifications as a JSON POST to the target URL.
+// The ID of the notification is sent as its Idempotency-Key.
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+// NewWebhookNotifier func to create a notifier that only reaches public
+// addresses, the target URLs come from users.
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		Client: utils.PublicHTTPClient(10 * time.Second),
+	}
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"subject": notification.Subject,
+		"body":    notification.Body,
+		"data":    notification.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if notification.ID != "" {
+		request.Header.Set("Idempotency-Key", notification.ID)
+	}
+
+	response, err := n.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Webhook responded with status %d", response.StatusCode)
+	}
+
+	return nil
+}
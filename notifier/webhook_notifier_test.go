@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+func TestWebhookNotifierSend(t *testing.T) {
+	var received struct {
+		contentType    string
+		idempotencyKey string
+		body           map[string]interface{}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.contentType = r.Header.Get("Content-Type")
+		received.idempotencyKey = r.Header.Get("Idempotency-Key")
+		if err := json.NewDecoder(r.Body).Decode(&received.body); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := &WebhookNotifier{Client: server.Client()}
+	err := n.Send(context.Background(), Notification{
+		ID:      "reminder.1700000000",
+		Target:  server.URL,
+		Subject: "Reminder: Buy milk",
+		Body:    "Reminder for your task: Buy milk",
+		Data:    map[string]interface{}{"task_id": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.contentType != "application/json" {
+		t.Errorf("Content-Type = %q", received.contentType)
+	}
+	if received.idempotencyKey != "reminder.1700000000" {
+		t.Errorf("Idempotency-Key = %q", received.idempotencyKey)
+	}
+	if received.body["subject"] != "Reminder: Buy milk" || received.body["data"].(map[string]interface{})["task_id"] != "1" {
+		t.Errorf("body = %v", received.body)
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := &WebhookNotifier{Client: server.Client()}
+	if err := n.Send(context.Background(), Notification{Target: server.URL}); err == nil {
+		t.Error("no error for a 500 response")
+	}
+}
+
+func TestWebhookNotifierPrivateAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier().Send(context.Background(), Notification{Target: server.URL})
+	if !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Errorf("error = %v, want %v", err, utils.ErrNonPublicAddress)
+	}
+	if called {
+		t.Error("request reached a loopback address")
+	}
+}
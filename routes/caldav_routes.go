@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+// CalDAVRoutes serve the projects of a user as calendars of VTODO resources.
+// CalDAV clients can not send a JWT, they sign in with an app password.
+func CalDAVRoutes(app *fiber.App) {
+	app.All("/.well-known/caldav", func(c *fiber.Ctx) error {
+		return c.Redirect("/dav/", fiber.StatusMovedPermanently)
+	})
+
+	route := app.Group("/dav", middleware.AppPasswordAuth())
+
+	route.Options("/*", controllers.DavOptions)
+	route.Add(controllers.MethodPropfind, "/", controllers.DavPrincipal)
+	route.Add(controllers.MethodPropfind, "/calendars", controllers.DavCalendars)
+	route.Add(controllers.MethodPropfind, "/calendars/:project", controllers.DavCalendar)
+	route.Add(controllers.MethodReport, "/calendars/:project", controllers.DavCalendarReport)
+	route.Add(controllers.MethodPropfind, "/calendars/:project/:name", controllers.DavTodoProps)
+	route.Get("/calendars/:project/:name", controllers.DavGetTodo)
+	route.Put("/calendars/:project/:name", controllers.DavPutTodo)
+	route.Delete("/calendars/:project/:name", controllers.DavDeleteTodo)
+}
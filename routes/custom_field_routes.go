@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+func CustomFieldRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/field")
+
+	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateCustomField)
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetCustomFields)
+	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.GetCustomField)
+	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.UpdateCustomField)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteCustomField)
+}
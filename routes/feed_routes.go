@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+)
+
+// FeedRoutes are authenticated by the secret in the URL.
+func FeedRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/feed")
+
+	route.Get("/:token.ics", controllers.GetCalendarFeed)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+func LabelRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/label")
+
+	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateLabel)
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetLabels)
+	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.GetLabel)
+	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.UpdateLabel)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteLabel)
+}
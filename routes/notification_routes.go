@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+func NotificationRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/notification")
+
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetNotifications)
+	route.Put("/:id/read", middleware.Auth(), middleware.ValidateJwt(), controllers.ReadNotification)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func ProjectRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/project")
+
+	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateProject)
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetProjects)
+	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleViewer), controllers.GetProject)
+	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleOwner), controllers.UpdateProject)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleOwner), controllers.DeleteProject)
+	route.Put("/:id/archive", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleOwner), controllers.ArchiveProject)
+	route.Put("/:id/unarchive", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleOwner), controllers.UnarchiveProject)
+
+	route.Get("/:id/members", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleViewer), controllers.GetProjectMembers)
+	route.Post("/:id/members", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.ProjectAccess(models.RoleOwner), controllers.AddProjectMember)
+	route.Put("/:id/members/:userId", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleOwner), controllers.UpdateProjectMember)
+	route.Delete("/:id/members/:userId", middleware.Auth(), middleware.ValidateJwt(), middleware.ProjectAccess(models.RoleViewer), controllers.RemoveProjectMember)
+}
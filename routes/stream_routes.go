@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+// StreamRoutes keep a connection open to push task events. Browsers can not
+// set headers on these connections, they may send the JWT as access_token.
+func StreamRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/stream")
+
+	route.Get("/", middleware.TokenFromQuery(), middleware.Auth(), middleware.ValidateJwt(), controllers.StreamTasks)
+	route.Get("/ws", middleware.TokenFromQuery(), middleware.Auth(), middleware.ValidateJwt(), controllers.UpgradeTaskSocket, websocket.New(controllers.TaskSocket))
+}
@@ -5,14 +5,50 @@ import (
 
 	"github.com/roshanpaturkar/go-tasks/controllers"
 	"github.com/roshanpaturkar/go-tasks/middleware"
+	"github.com/roshanpaturkar/go-tasks/models"
 )
 
 func TaskRoutes(app *fiber.App) {
 	route := app.Group("/api/v1/task")
 
-	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), controllers.CreateTask)
+	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateTask)
 	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetTasks)
-	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.GetTask)
-	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.UpdateTask)
-	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteTask)
+	route.Get("/search", middleware.Auth(), middleware.ValidateJwt(), controllers.SearchTasks)
+	route.Post("/batch", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.BatchTasks)
+	route.Get("/export", middleware.Auth(), middleware.ValidateJwt(), controllers.ExportTasks)
+	route.Post("/import", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.ImportTasks)
+	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetTask)
+	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.UpdateTask)
+	route.Patch("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.PatchTask)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.DeleteTask)
+
+	route.Get("/:id/children", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetTaskChildren)
+	route.Get("/:id/tree", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetTaskTree)
+	route.Put("/:id/move", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.MoveTask)
+	route.Put("/:id/project", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.MoveTaskToProject)
+
+	route.Post("/:id/dependencies", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleEditor), controllers.AddTaskDependency)
+	route.Delete("/:id/dependencies/:blockerId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.RemoveTaskDependency)
+
+	route.Post("/:id/labels", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleEditor), controllers.AssignTaskLabels)
+	route.Delete("/:id/labels/:labelId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.RemoveTaskLabel)
+
+	route.Post("/:id/comments", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleViewer), controllers.CreateComment)
+	route.Get("/:id/comments", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetComments)
+	route.Put("/:id/comments/:commentId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.UpdateComment)
+	route.Delete("/:id/comments/:commentId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.DeleteComment)
+
+	route.Post("/:id/attachments", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleEditor), controllers.UploadAttachment)
+	route.Get("/:id/attachments", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetAttachments)
+	route.Get("/:id/attachments/:attachmentId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.DownloadAttachment)
+	route.Delete("/:id/attachments/:attachmentId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleEditor), controllers.DeleteAttachment)
+
+	route.Get("/:id/history", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetTaskHistory)
+	route.Post("/:id/revert/:revision", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleEditor), controllers.RevertTask)
+
+	route.Get("/:id/occurrences", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetTaskOccurrences)
+
+	route.Post("/:id/reminders", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TaskAccess(models.RoleViewer), controllers.CreateReminder)
+	route.Get("/:id/reminders", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.GetReminders)
+	route.Delete("/:id/reminders/:reminderId", middleware.Auth(), middleware.ValidateJwt(), middleware.TaskAccess(models.RoleViewer), controllers.DeleteReminder)
 }
\ No newline at end of file
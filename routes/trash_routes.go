@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func TrashRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/trash")
+
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetTrash)
+	route.Delete("/", middleware.Auth(), middleware.ValidateJwt(), controllers.EmptyTrash)
+	route.Post("/:id/restore", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), middleware.TrashAccess(models.RoleEditor), controllers.RestoreTask)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), middleware.TrashAccess(models.RoleEditor), controllers.PurgeTask)
+}
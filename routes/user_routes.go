@@ -15,9 +15,15 @@ func UserRoutes(app *fiber.App) {
 	route.Get("/sign/out", middleware.Auth(), middleware.ValidateJwt(), controllers.UserSignOut)
 	route.Get("/sign/out/all", middleware.Auth(), middleware.ValidateJwt(), controllers.UserSignOutAll)
 	route.Get("/profile", middleware.Auth(), middleware.ValidateJwt(), controllers.UserProfile)
-	route.Post("/avatar", middleware.Auth(), middleware.ValidateJwt(), controllers.UploadUserAvatar)
+	route.Post("/avatar", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.UploadUserAvatar)
 	route.Get("/avatar", middleware.Auth(), middleware.ValidateJwt(), controllers.GetUserAvatar)
 	route.Get("/avatar/:id", controllers.GetAvatarById)
 	route.Delete("/avatar", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteUserAvatar)
-	route.Post("/change/password", middleware.Auth(), middleware.ValidateJwt(), controllers.ChangeUserPassword)
+	route.Post("/change/password", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.ChangeUserPassword)
+	route.Get("/feed", middleware.Auth(), middleware.ValidateJwt(), controllers.GetCalendarFeedStatus)
+	route.Post("/feed", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.RotateCalendarFeed)
+	route.Delete("/feed", middleware.Auth(), middleware.ValidateJwt(), controllers.RevokeCalendarFeed)
+	route.Post("/app-passwords", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateAppPassword)
+	route.Get("/app-passwords", middleware.Auth(), middleware.ValidateJwt(), controllers.GetAppPasswords)
+	route.Delete("/app-passwords/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteAppPassword)
 }
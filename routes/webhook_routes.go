@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/roshanpaturkar/go-tasks/controllers"
+	"github.com/roshanpaturkar/go-tasks/middleware"
+)
+
+func WebhookRoutes(app *fiber.App) {
+	route := app.Group("/api/v1/webhook")
+
+	route.Post("/", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.CreateWebhook)
+	route.Get("/", middleware.Auth(), middleware.ValidateJwt(), controllers.GetWebhooks)
+	route.Get("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.GetWebhook)
+	route.Put("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.UpdateWebhook)
+	route.Delete("/:id", middleware.Auth(), middleware.ValidateJwt(), controllers.DeleteWebhook)
+	route.Post("/:id/secret", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.RotateWebhookSecret)
+	route.Get("/:id/deliveries", middleware.Auth(), middleware.ValidateJwt(), controllers.GetWebhookDeliveries)
+	route.Get("/:id/deliveries/:delivery", middleware.Auth(), middleware.ValidateJwt(), controllers.GetWebhookDelivery)
+	route.Post("/:id/deliveries/:delivery/redeliver", middleware.Auth(), middleware.ValidateJwt(), middleware.Idempotency(), controllers.RedeliverWebhook)
+}
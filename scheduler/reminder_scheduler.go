@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/notifier"
+)
+
+const (
+	reminderInterval    = 15 * time.Second
+	reminderLease       = 2 * time.Minute
+	reminderMaxAttempts = 5
+)
+
+var errReminderGone = errors.New("Task or user no longer exists")
+
+// ReminderScheduler fires due reminders through their notifier. A reminder is
+// claimed with an atomic pending -> firing transition before it is sent, so
+// several instances can run side by side without sending it twice. A lease
+// hands reminders back if an instance dies while sending one. An instance
+// dying after the send but before it is recorded makes the reminder go out
+// again, so delivery is at least once. Every send of a reminder carries the
+// same notification ID, which receivers use to drop the repeat.
+type ReminderScheduler struct {
+	db        *mongo.Database
+	notifiers map[string]notifier.Notifier
+}
+
+func NewReminderScheduler(db *mongo.Database, notifiers map[string]notifier.Notifier) *ReminderScheduler {
+	return &ReminderScheduler{db: db, notifiers: notifiers}
+}
+
+// Run func to poll for due reminders until the context is cancelled.
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	log.Println("Reminder scheduler started")
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		s.fireDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Reminder scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReminderScheduler) fireDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		reminder, err := s.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			log.Printf("Reminder claim failed: %v\n", err)
+			return
+		}
+
+		s.fire(ctx, reminder)
+	}
+}
+
+func (s *ReminderScheduler) claim(ctx context.Context) (*models.Reminder, error) {
+	now := time.Now().Unix()
+	reminder := new(models.Reminder)
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.ReminderPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"status": models.ReminderFiring, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": models.ReminderFiring, "locked_until": now + int64(reminderLease.Seconds()), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	err := s.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(reminder)
+
+	return reminder, err
+}
+
+func (s *ReminderScheduler) fire(ctx context.Context, reminder *models.Reminder) {
+	notification, err := s.notification(ctx, reminder)
+	if err == nil {
+		if channel, ok := s.notifiers[reminder.Channel]; ok {
+			sendCtx, cancel := context.WithTimeout(ctx, reminderLease/2)
+			err = channel.Send(sendCtx, *notification)
+			cancel()
+		} else {
+			err = fmt.Errorf("Unknown channel %s", reminder.Channel)
+		}
+	}
+
+	now := time.Now().Unix()
+	update := bson.M{"status": models.ReminderSent, "sent_at": now, "updated_at": now}
+
+	if err != nil {
+		log.Printf("Reminder %s failed: %v\n", reminder.ID.Hex(), err)
+
+		// Retry with exponential backoff until the attempts run out
+		update = bson.M{"status": models.ReminderFailed, "last_error": err.Error(), "updated_at": now}
+		if reminder.Attempts < reminderMaxAttempts && err != errReminderGone {
+			update["status"] = models.ReminderPending
+			update["next_attempt_at"] = now + int64(30<<reminder.Attempts)
+		}
+	}
+
+	// Only the claim that is still holding the reminder may finish it
+	if _, err := s.collection().UpdateOne(ctx, bson.M{"_id": reminder.ID, "status": models.ReminderFiring, "attempts": reminder.Attempts}, bson.M{"$set": update}); err != nil {
+		log.Printf("Reminder %s could not be updated: %v\n", reminder.ID.Hex(), err)
+	}
+}
+
+func (s *ReminderScheduler) notification(ctx context.Context, reminder *models.Reminder) (*notifier.Notification, error) {
+	task := new(models.Task)
+	if err := s.db.Collection(os.Getenv("TASKS_COLLECTION")).FindOne(ctx, bson.M{"_id": reminder.TaskId}).Decode(task); err != nil {
+		return nil, errReminderGone
+	}
+
+	user := new(models.User)
+	if err := s.db.Collection(os.Getenv("USER_COLLECTION")).FindOne(ctx, bson.M{"_id": reminder.UserId}).Decode(user); err != nil {
+		return nil, errReminderGone
+	}
+
+	target := reminder.Target
+	if reminder.Channel == "email" {
+		target = user.Email
+	}
+
+	body := "Reminder for your task: " + task.Title
+	if task.DueAt != nil {
+		body += "\nDue: " + time.Unix(*task.DueAt, 0).UTC().Format(time.RFC1123)
+	}
+
+	return &notifier.Notification{
+		ID:      reminder.ID.Hex() + "." + strconv.FormatInt(reminder.RemindAt, 10),
+		Target:  target,
+		Subject: "Reminder: " + task.Title,
+		Body:    body,
+		Data: map[string]interface{}{
+			"reminder_id": reminder.ID.Hex(),
+			"task_id":     task.ID.Hex(),
+			"title":       task.Title,
+			"due_at":      task.DueAt,
+			"remind_at":   reminder.RemindAt,
+		},
+	}, nil
+}
+
+func (s *ReminderScheduler) collection() *mongo.Collection {
+	return s.db.Collection(os.Getenv("REMINDERS_COLLECTION"))
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+	"github.com/roshanpaturkar/go-tasks/utils"
+)
+
+const (
+	webhookInterval    = 5 * time.Second
+	webhookLease       = time.Minute
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 8
+	webhookLogSize     = 20
+)
+
+var (
+	errWebhookGone     = errors.New("Webhook no longer exists")
+	errWebhookDisabled = errors.New("Webhook is disabled")
+)
+
+// WebhookScheduler posts queued webhook deliveries. Deliveries are claimed
+// the same way as reminders, every attempt is added to the log of the
+// delivery and failed ones are retried with exponential backoff.
+type WebhookScheduler struct {
+	db     *mongo.Database
+	client *http.Client
+}
+
+func NewWebhookScheduler(db *mongo.Database) *WebhookScheduler {
+	return &WebhookScheduler{
+		db:     db,
+		client: webhookClient(),
+	}
+}
+
+// webhookClient func to return the client deliveries are posted with. It only
+// reaches public addresses, and redirects are answered as they are, the
+// receiver has to give the URL the delivery should go to.
+func webhookClient() *http.Client {
+	client := utils.PublicHTTPClient(webhookTimeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return client
+}
+
+// Run func to poll for due deliveries until the context is cancelled.
+func (s *WebhookScheduler) Run(ctx context.Context) {
+	log.Println("Webhook scheduler started")
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Webhook scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebhookScheduler) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := s.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			log.Printf("Webhook delivery claim failed: %v\n", err)
+			return
+		}
+
+		s.deliver(ctx, delivery)
+	}
+}
+
+func (s *WebhookScheduler) claim(ctx context.Context) (*models.WebhookDelivery, error) {
+	now := time.Now().Unix()
+	delivery := new(models.WebhookDelivery)
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"status": models.DeliverySending, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": models.DeliverySending, "locked_until": now + int64(webhookLease.Seconds()), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	err := s.deliveries().FindOneAndUpdate(ctx, filter, update, opts).Decode(delivery)
+
+	return delivery, err
+}
+
+func (s *WebhookScheduler) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	started := time.Now()
+	attempt := models.WebhookAttempt{At: started.Unix()}
+
+	webhook, err := s.webhook(ctx, delivery)
+	if err == nil {
+		attempt.StatusCode, err = s.post(ctx, webhook, delivery)
+	}
+	attempt.Duration = time.Since(started).Milliseconds()
+
+	now := time.Now().Unix()
+	update := bson.M{"status": models.DeliverySucceeded, "delivered_at": now, "updated_at": now}
+
+	if err != nil {
+		attempt.Error = err.Error()
+
+		// Retry with exponential backoff until the attempts run out
+		update = bson.M{"status": models.DeliveryFailed, "updated_at": now}
+		if delivery.Attempts < webhookMaxAttempts && err != errWebhookGone && err != errWebhookDisabled {
+			update["status"] = models.DeliveryPending
+			update["next_attempt_at"] = now + int64(30<<delivery.Attempts)
+		}
+	}
+
+	// Only the claim that is still holding the delivery may finish it
+	filter := bson.M{"_id": delivery.ID, "status": models.DeliverySending, "attempts": delivery.Attempts}
+	push := bson.M{"log": bson.M{"$each": bson.A{attempt}, "$slice": -webhookLogSize}}
+	if _, err := s.deliveries().UpdateOne(ctx, filter, bson.M{"$set": update, "$push": push}); err != nil {
+		log.Printf("Webhook delivery %s could not be updated: %v\n", delivery.ID.Hex(), err)
+	}
+}
+
+func (s *WebhookScheduler) webhook(ctx context.Context, delivery *models.WebhookDelivery) (*models.Webhook, error) {
+	webhook := new(models.Webhook)
+	if err := s.db.Collection(os.Getenv("WEBHOOKS_COLLECTION")).FindOne(ctx, bson.M{"_id": delivery.WebhookId}).Decode(webhook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errWebhookGone
+		}
+		return nil, err
+	}
+
+	if !webhook.Active {
+		return nil, errWebhookDisabled
+	}
+
+	return webhook, nil
+}
+
+// post func to send a delivery and return the status of the response. Its
+// body is not read, receivers could otherwise be made to relay what they
+// fetch. Anything but a 2xx is an error.
+func (s *WebhookScheduler) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "go-tasks-webhooks")
+	request.Header.Set("X-Webhook-Id", delivery.ID.Hex())
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Signature", utils.WebhookSignature(webhook.Secret, time.Now().Unix(), body))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("Webhook responded with status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+func (s *WebhookScheduler) deliveries() *mongo.Collection {
+	return s.db.Collection(os.Getenv("WEBHOOK_DELIVERIES_COLLECTION"))
+}
@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+var (
+	busMu  sync.RWMutex
+	busHub *Hub
+)
+
+// ListenBus func to make the hub read events from the in-process bus. Only
+// changes made by this instance reach it, so it is meant for deployments
+// without a replica set to watch.
+func (h *Hub) ListenBus() {
+	busMu.Lock()
+	defer busMu.Unlock()
+
+	busHub = h
+}
+
+// Publish func to put a change of a task on the in-process bus. It does
+// nothing while the hub reads from a change stream instead.
+func Publish(eventType string, task models.Task) {
+	busMu.RLock()
+	hub := busHub
+	busMu.RUnlock()
+
+	if hub == nil {
+		return
+	}
+
+	hub.Publish(Event{ID: primitive.NewObjectID().Hex(), Type: eventType, Task: task})
+}
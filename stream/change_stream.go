@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+const watchRetry = 5 * time.Second
+
+// taskChange is the part of a change event of the tasks collection the hub
+// reads. FullDocument is the task as it was looked up after the change.
+type taskChange struct {
+	ID                bson.Raw            `bson:"_id"`
+	OperationType     string              `bson:"operationType"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+	FullDocument      *models.Task        `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// WatchTasks func to feed the hub from a change stream on the tasks
+// collection, which needs a replica set. The error of opening the stream is
+// returned, once it is open it is read until the context is cancelled and
+// reopened after the last change it delivered when it fails.
+func (h *Hub) WatchTasks(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection(os.Getenv("TASKS_COLLECTION"))
+
+	changes, err := watchTasks(ctx, collection, nil)
+	if err != nil {
+		return err
+	}
+
+	go h.readChanges(ctx, collection, changes)
+
+	return nil
+}
+
+func watchTasks(ctx context.Context, collection *mongo.Collection, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}}}}},
+	}
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+
+	return collection.Watch(ctx, pipeline, opts)
+}
+
+func (h *Hub) readChanges(ctx context.Context, collection *mongo.Collection, changes *mongo.ChangeStream) {
+	log.Println("Task change stream started")
+
+	for {
+		for changes.Next(ctx) {
+			change := new(taskChange)
+			if err := changes.Decode(change); err != nil {
+				log.Printf("Decoding a task change failed: %v\n", err)
+				continue
+			}
+
+			if event, ok := change.event(); ok {
+				h.Publish(event)
+			}
+		}
+
+		resumeToken := changes.ResumeToken()
+		err := changes.Err()
+		changes.Close(context.Background())
+
+		if ctx.Err() != nil {
+			log.Println("Task change stream stopped")
+			return
+		}
+		log.Printf("Task change stream failed: %v\n", err)
+
+		// A token the oplog no longer covers can not be resumed from, the
+		// stream then starts over from now
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("Task change stream stopped")
+				return
+			case <-time.After(watchRetry):
+			}
+
+			if changes, err = watchTasks(ctx, collection, resumeToken); err == nil {
+				break
+			}
+			log.Printf("Reopening the task change stream failed: %v\n", err)
+			resumeToken = nil
+		}
+	}
+}
+
+// event func to turn a change into the event sessions get. Trashing a task
+// is its delete and restoring it its create, other changes of trashed tasks
+// are not sent.
+func (change *taskChange) event() (Event, bool) {
+	task := change.FullDocument
+	if task == nil {
+		return Event{}, false
+	}
+
+	eventType := models.EventTaskUpdated
+	if change.OperationType == "insert" {
+		eventType = models.EventTaskCreated
+	} else if task.DeletedAt != nil {
+		if _, ok := change.UpdateDescription.UpdatedFields["deleted_at"]; !ok {
+			return Event{}, false
+		}
+		eventType = models.EventTaskDeleted
+	} else {
+		for _, field := range change.UpdateDescription.RemovedFields {
+			if field == "deleted_at" {
+				eventType = models.EventTaskCreated
+			}
+		}
+	}
+
+	id, ok := change.ID.Lookup("_data").StringValueOK()
+	if !ok {
+		id = change.ID.String()
+	}
+
+	return Event{ID: id, Type: eventType, Task: *task, Order: change.ClusterTime}, true
+}
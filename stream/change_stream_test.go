@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func TestTaskChangeEvent(t *testing.T) {
+	deletedAt := int64(1700000000)
+
+	tests := []struct {
+		name    string
+		change  taskChange
+		want    string
+		skipped bool
+	}{
+		{
+			name:   "insert",
+			change: taskChange{OperationType: "insert", FullDocument: &models.Task{}},
+			want:   models.EventTaskCreated,
+		},
+		{
+			name:   "update",
+			change: taskChange{OperationType: "update", FullDocument: &models.Task{}},
+			want:   models.EventTaskUpdated,
+		},
+		{
+			name: "trash",
+			change: func() taskChange {
+				change := taskChange{OperationType: "update", FullDocument: &models.Task{DeletedAt: &deletedAt}}
+				change.UpdateDescription.UpdatedFields = bson.M{"deleted_at": deletedAt}
+				return change
+			}(),
+			want: models.EventTaskDeleted,
+		},
+		{
+			name:    "change in the trash",
+			change:  taskChange{OperationType: "update", FullDocument: &models.Task{DeletedAt: &deletedAt}},
+			skipped: true,
+		},
+		{
+			name: "restore",
+			change: func() taskChange {
+				change := taskChange{OperationType: "update", FullDocument: &models.Task{}}
+				change.UpdateDescription.RemovedFields = []string{"purge_at", "deleted_at"}
+				return change
+			}(),
+			want: models.EventTaskCreated,
+		},
+		{
+			name:    "deleted for good",
+			change:  taskChange{OperationType: "update"},
+			skipped: true,
+		},
+	}
+
+	for _, test := range tests {
+		test.change.ID, _ = bson.Marshal(bson.M{"_data": "token"})
+
+		event, ok := test.change.event()
+		if ok == test.skipped {
+			t.Errorf("%s: sent = %v, want %v", test.name, ok, !test.skipped)
+			continue
+		}
+		if ok && (event.Type != test.want || event.ID != "token") {
+			t.Errorf("%s: event = %s %s, want %s token", test.name, event.Type, event.ID, test.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+const (
+	subscriptionSize = 64
+
+	// A session that missed more events than this reloads its tasks instead
+	maxMissedEvents = 1000
+
+	defaultEventRetentionHours = 24
+
+	eventStoreTimeout = 10 * time.Second
+)
+
+// Event is a change of a task, sent to every session of its owner. IDs are
+// opaque, sessions hand the last one they saw back to resume. Order sorts the
+// events of a user, it is the cluster time of the change when it comes from
+// the change stream.
+type Event struct {
+	ID        string              `bson:"_id"`
+	Type      string              `bson:"type"`
+	Task      models.Task         `bson:"task"`
+	Order     primitive.Timestamp `bson:"order"`
+	CreatedAt int64               `bson:"created_at"`
+	ExpireAt  time.Time           `bson:"expire_at"`
+}
+
+// Subscription is a session of a user listening for their events. Events is
+// closed when the session falls too far behind, it should reconnect and
+// resume from the last event it saw.
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	userID primitive.ObjectID
+}
+
+// Hub fans task events out to the sessions of their owner. Events are kept
+// per user in EVENTS_COLLECTION for EVENT_RETENTION_HOURS, so sessions can
+// resume after a reconnect to any instance or a restart. Events come from a
+// change stream on the tasks collection or, without a replica set, from the
+// in-process bus.
+type Hub struct {
+	db            *mongo.Database
+	mu            sync.Mutex
+	subscriptions map[primitive.ObjectID]map[*Subscription]struct{}
+	lastOrder     primitive.Timestamp
+}
+
+func NewHub(db *mongo.Database) *Hub {
+	return &Hub{
+		db:            db,
+		subscriptions: map[primitive.ObjectID]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe func to add a session of a user. The events of the user after
+// lastEventID are returned to be sent first. ok is false when lastEventID is
+// no longer known or too much was missed, the session should reload its
+// tasks.
+func (h *Hub) Subscribe(ctx context.Context, userID primitive.ObjectID, lastEventID string) (*Subscription, []Event, bool) {
+	events := make(chan Event, subscriptionSize)
+	subscription := &Subscription{Events: events, events: events, userID: userID}
+
+	// Events published from now on reach the session, so none are lost
+	// between reading the history and subscribing
+	h.mu.Lock()
+	if h.subscriptions[userID] == nil {
+		h.subscriptions[userID] = map[*Subscription]struct{}{}
+	}
+	h.subscriptions[userID][subscription] = struct{}{}
+	h.mu.Unlock()
+
+	if lastEventID == "" {
+		return subscription, nil, true
+	}
+
+	missed, ok, err := h.missedEvents(ctx, userID, lastEventID)
+	if err != nil {
+		log.Printf("Reading the missed events of user %s failed: %v\n", userID.Hex(), err)
+		return subscription, nil, false
+	}
+	if !ok {
+		return subscription, nil, false
+	}
+
+	// Events published while the history was read may be in both, the
+	// session gets them once
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[userID][subscription]; !ok {
+		return subscription, missed, true
+	}
+
+	sent := map[string]struct{}{}
+	for _, event := range missed {
+		sent[event.ID] = struct{}{}
+	}
+
+	pending := []Event{}
+	for len(events) > 0 {
+		event := <-events
+		if _, ok := sent[event.ID]; !ok {
+			pending = append(pending, event)
+		}
+	}
+	for _, event := range pending {
+		events <- event
+	}
+
+	return subscription, missed, true
+}
+
+// missedEvents func to read the history of a user after lastEventID.
+func (h *Hub) missedEvents(ctx context.Context, userID primitive.ObjectID, lastEventID string) ([]Event, bool, error) {
+	collection := h.db.Collection(os.Getenv("EVENTS_COLLECTION"))
+
+	last := new(Event)
+	err := collection.FindOne(ctx, bson.M{"_id": lastEventID, "task.user_id": userID}).Decode(last)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "order", Value: 1}}).
+		SetLimit(maxMissedEvents + 1)
+
+	cursor, err := collection.Find(ctx, bson.M{"task.user_id": userID, "order": bson.M{"$gt": last.Order}}, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	missed := []Event{}
+	if err := cursor.All(ctx, &missed); err != nil {
+		return nil, false, err
+	}
+	if len(missed) > maxMissedEvents {
+		return nil, false, nil
+	}
+
+	return missed, true, nil
+}
+
+// Unsubscribe func to remove a session once it is gone.
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(subscription)
+}
+
+// Publish func to add an event to the history of the owner of its task and
+// send it to their sessions. Every instance watching the change stream
+// publishes the same events, the history keeps each once. The history is
+// written in the background so the change that caused the event does not wait
+// for it, a session resuming from an event that is not stored yet reloads its
+// tasks. Sessions that can not keep up are dropped rather than holding up the
+// others.
+func (h *Hub) Publish(event Event) {
+	now := time.Now()
+	if event.CreatedAt == 0 {
+		event.CreatedAt = now.Unix()
+	}
+	event.ExpireAt = now.Add(eventRetention())
+
+	h.mu.Lock()
+	if event.Order.IsZero() {
+		event.Order = h.nextOrder(now)
+	}
+	h.mu.Unlock()
+
+	go h.store(event)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscription := range h.subscriptions[event.Task.UserId] {
+		select {
+		case subscription.events <- event:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+// store func to add an event to the history, events other instances stored
+// already are skipped.
+func (h *Hub) store(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), eventStoreTimeout)
+	defer cancel()
+
+	if _, err := h.db.Collection(os.Getenv("EVENTS_COLLECTION")).InsertOne(ctx, event); err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Printf("Storing stream event %s failed: %v\n", event.ID, err)
+	}
+}
+
+// nextOrder func to order the events of the in-process bus like cluster
+// times, by second and then by a counter.
+func (h *Hub) nextOrder(now time.Time) primitive.Timestamp {
+	if seconds := uint32(now.Unix()); seconds > h.lastOrder.T {
+		h.lastOrder = primitive.Timestamp{T: seconds, I: 1}
+	} else {
+		h.lastOrder.I++
+	}
+
+	return h.lastOrder
+}
+
+func (h *Hub) remove(subscription *Subscription) {
+	subscriptions, ok := h.subscriptions[subscription.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subscriptions[subscription]; !ok {
+		return
+	}
+
+	delete(subscriptions, subscription)
+	close(subscription.events)
+	if len(subscriptions) == 0 {
+		delete(h.subscriptions, subscription.userID)
+	}
+}
+
+// eventRetention func to read how long events are kept for sessions to
+// resume.
+func eventRetention() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EVENT_RETENTION_HOURS"))
+	if err != nil || hours < 1 {
+		hours = defaultEventRetentionHours
+	}
+
+	return time.Duration(hours) * time.Hour
+}
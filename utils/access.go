@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+var roleRanks = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// ErrForbidden is returned when the user can see a task or project but the
+// action needs a higher role.
+var ErrForbidden = errors.New("Forbidden")
+
+// RoleAllows func to check if a role includes the permissions of required.
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// ProjectRole func to return the role of the user on a project, or an empty
+// string when it is not shared with them.
+func ProjectRole(project *models.Project, userID primitive.ObjectID) string {
+	if project.UserId == userID {
+		return models.RoleOwner
+	}
+
+	for _, member := range project.Members {
+		if member.UserId == userID {
+			return member.Role
+		}
+	}
+
+	return ""
+}
+
+// ProjectAccess func to load a project the user has at least the required
+// role on. Projects the user cannot see are reported as missing.
+func ProjectAccess(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID, required string) (*models.Project, error) {
+	project := new(models.Project)
+	if err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).FindOne(ctx, bson.M{"_id": id}).Decode(project); err != nil {
+		return nil, err
+	}
+
+	role := ProjectRole(project, userID)
+	if role == "" {
+		return nil, mongo.ErrNoDocuments
+	}
+	if !RoleAllows(role, required) {
+		return nil, ErrForbidden
+	}
+
+	return project, nil
+}
+
+// TaskAccess func to load a task the user has at least the required role on
+// through its project. Tasks outside any project are only open to their owner,
+// tasks in the trash are not found.
+func TaskAccess(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID, required string) (*models.Task, error) {
+	return taskAccess(ctx, db, userID, bson.M{"_id": id, "deleted_at": bson.M{"$exists": false}}, required)
+}
+
+// TrashedTaskAccess func to load a task the user deleted, or one of the tasks
+// of a project they have the required role on. Subtasks deleted along with
+// their parent are only reachable through the parent.
+func TrashedTaskAccess(ctx context.Context, db *mongo.Database, userID, id primitive.ObjectID, required string) (*models.Task, error) {
+	return taskAccess(ctx, db, userID, bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}, "trashed_with": bson.M{"$exists": false}}, required)
+}
+
+func taskAccess(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, filter bson.M, required string) (*models.Task, error) {
+	task := new(models.Task)
+	if err := db.Collection(os.Getenv("TASKS_COLLECTION")).FindOne(ctx, filter).Decode(task); err != nil {
+		return nil, err
+	}
+
+	if task.UserId == userID {
+		return task, nil
+	}
+
+	if task.ProjectId.IsZero() {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	if _, err := ProjectAccess(ctx, db, userID, task.ProjectId, required); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// SharedProjectIDs func to list the projects other users share with the user.
+func SharedProjectIDs(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ids, err := db.Collection(os.Getenv("PROJECTS_COLLECTION")).Distinct(ctx, "_id", bson.M{"members.user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		projectIDs = append(projectIDs, id.(primitive.ObjectID))
+	}
+
+	return projectIDs, nil
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	// MaxImportFileSize is the largest file a task import reads
+	MaxImportFileSize = 5 << 20
+
+	defaultAttachmentMaxFileSize = 10 * 1024 * 1024
+)
+
+// ErrAttachmentTooLarge is returned by SizeLimitReader once more than its
+// limit has been read.
+var ErrAttachmentTooLarge = errors.New("Attachment is too large")
+
+// AttachmentMaxFileSize func to read the size of the largest attachment from
+// ATTACHMENT_MAX_FILE_SIZE.
+func AttachmentMaxFileSize() int64 {
+	size, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_FILE_SIZE"), 10, 64)
+	if err != nil {
+		return defaultAttachmentMaxFileSize
+	}
+
+	return size
+}
+
+// SniffContentType func to detect the content type of a stream from its first
+// bytes. The returned reader still yields the whole stream.
+func SniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// SizeLimitReader func to wrap r so that it fails with ErrAttachmentTooLarge
+// instead of silently truncating when r holds more than limit bytes.
+func SizeLimitReader(r io.Reader, limit int64) io.Reader {
+	return &sizeLimitReader{r: r, limit: limit}
+}
+
+type sizeLimitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		return 0, ErrAttachmentTooLarge
+	}
+
+	// Read one byte past the limit to tell a full stream from a larger one
+	if remaining := l.limit - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, ErrAttachmentTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// FieldErrors maps the fields of a request to what is wrong with them.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, 0, len(keys))
+	for _, key := range keys {
+		messages = append(messages, key+" "+e[key])
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// CheckCustomField func to check the definition of a custom field and turn its
+// default into a value of its type.
+func CheckCustomField(field *models.CustomField) error {
+	if !metadataKeyPattern.MatchString(field.Name) {
+		return errors.New("name may only use letters, digits, - and _")
+	}
+
+	switch field.Type {
+	case models.FieldEnum, models.FieldMultiSelect:
+		if len(field.Options) == 0 {
+			return errors.New("options are required for " + field.Type + " fields")
+		}
+		seen := map[string]bool{}
+		for _, option := range field.Options {
+			if seen[option] {
+				return errors.New("options must be unique")
+			}
+			seen[option] = true
+		}
+	default:
+		if len(field.Options) > 0 {
+			return errors.New("only enum and multi-select fields have options")
+		}
+	}
+
+	if field.Default != nil {
+		value, err := FieldValue(field, field.Default)
+		if err != nil {
+			return errors.New("default " + err.Error())
+		}
+		field.Default = value
+	}
+
+	return nil
+}
+
+// FieldValue func to turn a value read from JSON or Mongo into the value a
+// custom field stores.
+func FieldValue(field *models.CustomField, value interface{}) (interface{}, error) {
+	switch field.Type {
+	case models.FieldText:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+		return nil, errors.New("must be a string")
+
+	case models.FieldNumber:
+		if number, ok := numberValue(value); ok {
+			return number, nil
+		}
+		return nil, errors.New("must be a number")
+
+	case models.FieldDate:
+		if number, ok := numberValue(value); ok && number > 0 && number == float64(int64(number)) {
+			return int64(number), nil
+		}
+		return nil, errors.New("must be a unix timestamp")
+
+	case models.FieldBool:
+		if flag, ok := value.(bool); ok {
+			return flag, nil
+		}
+		return nil, errors.New("must be true or false")
+
+	case models.FieldEnum:
+		if option, ok := value.(string); ok && hasOption(field, option) {
+			return option, nil
+		}
+		return nil, errors.New("must be one of " + strings.Join(field.Options, ", "))
+
+	case models.FieldMultiSelect:
+		items, ok := listValue(value)
+		if !ok {
+			return nil, errors.New("must be a list of options")
+		}
+		options := make([]string, 0, len(items))
+		seen := map[string]bool{}
+		for _, item := range items {
+			option, ok := item.(string)
+			if !ok || !hasOption(field, option) {
+				return nil, errors.New("may only hold " + strings.Join(field.Options, ", "))
+			}
+			if !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+		return options, nil
+	}
+
+	return nil, errors.New("has an unknown type")
+}
+
+// WithFieldDefaults func to fill in the defaults of the custom fields that are
+// missing from the metadata of a new task.
+func WithFieldDefaults(fields []models.CustomField, metadata map[string]interface{}) map[string]interface{} {
+	withDefaults := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		withDefaults[key] = value
+	}
+
+	for _, field := range fields {
+		if _, ok := withDefaults[field.Name]; !ok && field.Default != nil {
+			withDefaults[field.Name] = field.Default
+		}
+	}
+
+	return withDefaults
+}
+
+// CoerceFieldValue func to convert a value a task held before its key became a
+// custom field into a value of the field, like the text "5" for a number
+// field. False when it can not be converted.
+func CoerceFieldValue(field *models.CustomField, value interface{}) (interface{}, bool) {
+	if typed, err := FieldValue(field, value); err == nil {
+		return typed, true
+	}
+
+	var converted interface{}
+	switch value := value.(type) {
+	case string:
+		text := strings.TrimSpace(value)
+		switch field.Type {
+		case models.FieldNumber:
+			if number, err := strconv.ParseFloat(text, 64); err == nil {
+				converted = number
+			}
+		case models.FieldDate:
+			if timestamp, err := strconv.ParseInt(text, 10, 64); err == nil {
+				converted = timestamp
+			} else if date, err := time.Parse(time.RFC3339, text); err == nil {
+				converted = date.Unix()
+			} else if date, err := time.Parse("2006-01-02", text); err == nil {
+				converted = date.Unix()
+			}
+		case models.FieldBool:
+			if flag, err := strconv.ParseBool(text); err == nil {
+				converted = flag
+			}
+		case models.FieldEnum, models.FieldMultiSelect:
+			for _, option := range field.Options {
+				if strings.EqualFold(option, text) {
+					converted = option
+				}
+			}
+			if converted != nil && field.Type == models.FieldMultiSelect {
+				converted = []interface{}{converted}
+			}
+		}
+	case bool:
+		if field.Type == models.FieldText {
+			converted = strconv.FormatBool(value)
+		}
+	default:
+		if number, ok := numberValue(value); ok && field.Type == models.FieldText {
+			converted = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+	}
+
+	if converted == nil {
+		return nil, false
+	}
+
+	typed, err := FieldValue(field, converted)
+	return typed, err == nil
+}
+
+// CheckTaskMetadata func to check the metadata of a task against the custom
+// fields of its owner. Keys with a field get values of its type and required
+// fields must be set, other keys are free form but can not hold objects. Null
+// values are dropped. Problems are returned as FieldErrors. Previous is the
+// metadata the task holds, nil for a new task: the values it already has are
+// kept as they are, so values from before a field was defined or changed do
+// not block updates of other keys.
+func CheckTaskMetadata(fields []models.CustomField, metadata, previous map[string]interface{}) (map[string]interface{}, error) {
+	fieldErrors := FieldErrors{}
+	checked := make(map[string]interface{}, len(metadata))
+
+	byName := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byName[fields[i].Name] = &fields[i]
+	}
+
+	for key, value := range metadata {
+		if value == nil {
+			continue
+		}
+		if !metadataKeyPattern.MatchString(key) {
+			fieldErrors["metadata."+key] = "keys may only use letters, digits, - and _"
+			continue
+		}
+
+		if old, ok := previous[key]; ok && sameMetadataValue(old, value) {
+			checked[key] = value
+			continue
+		}
+
+		field, ok := byName[key]
+		if !ok {
+			switch value.(type) {
+			case map[string]interface{}, primitive.M, primitive.D:
+				fieldErrors["metadata."+key] = "must not be an object"
+			default:
+				checked[key] = value
+			}
+			continue
+		}
+
+		typed, err := FieldValue(field, value)
+		if err != nil {
+			fieldErrors["metadata."+key] = err.Error()
+			continue
+		}
+		checked[key] = typed
+	}
+
+	for _, field := range fields {
+		key := "metadata." + field.Name
+		if _, had := previous[field.Name]; previous != nil && !had {
+			continue
+		}
+		if _, ok := checked[field.Name]; field.Required && !ok && fieldErrors[key] == "" {
+			fieldErrors[key] = "is required"
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
+	return checked, nil
+}
+
+// sameMetadataValue func to compare values read from Mongo with values read
+// from JSON, whose numbers and lists have other types.
+func sameMetadataValue(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+func hasOption(field *models.CustomField, option string) bool {
+	for _, value := range field.Options {
+		if value == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func numberValue(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int64:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	}
+
+	return 0, false
+}
+
+func listValue(value interface{}) ([]interface{}, bool) {
+	switch list := value.(type) {
+	case []interface{}:
+		return list, true
+	case primitive.A:
+		return list, true
+	case []string:
+		items := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			items = append(items, item)
+		}
+		return items, true
+	}
+
+	return nil, false
+}
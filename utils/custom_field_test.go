@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func TestCoerceFieldValue(t *testing.T) {
+	tests := []struct {
+		field models.CustomField
+		value interface{}
+		want  interface{}
+		ok    bool
+	}{
+		{models.CustomField{Type: models.FieldNumber}, float64(5), float64(5), true},
+		{models.CustomField{Type: models.FieldNumber}, " 5.5 ", 5.5, true},
+		{models.CustomField{Type: models.FieldNumber}, "five", nil, false},
+		{models.CustomField{Type: models.FieldDate}, "1700000000", int64(1700000000), true},
+		{models.CustomField{Type: models.FieldDate}, "2024-01-02", int64(1704153600), true},
+		{models.CustomField{Type: models.FieldDate}, "2024-01-02T01:00:00+01:00", int64(1704153600), true},
+		{models.CustomField{Type: models.FieldBool}, "true", true, true},
+		{models.CustomField{Type: models.FieldText}, int32(7), "7", true},
+		{models.CustomField{Type: models.FieldText}, false, "false", true},
+		{models.CustomField{Type: models.FieldEnum, Options: []string{"Low", "High"}}, "high", "High", true},
+		{models.CustomField{Type: models.FieldEnum, Options: []string{"Low", "High"}}, "urgent", nil, false},
+		{models.CustomField{Type: models.FieldMultiSelect, Options: []string{"a", "b"}}, "A", []string{"a"}, true},
+	}
+
+	for _, test := range tests {
+		got, ok := CoerceFieldValue(&test.field, test.value)
+		if ok != test.ok || (ok && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("CoerceFieldValue(%s, %#v) = %#v, %v, want %#v, %v", test.field.Type, test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestCheckTaskMetadataChangedKeys(t *testing.T) {
+	fields := []models.CustomField{
+		{Name: "points", Type: models.FieldNumber},
+		{Name: "owner", Type: models.FieldText, Required: true},
+	}
+
+	// A value from before the field existed and a required field that was
+	// never set do not block changing another key
+	previous := map[string]interface{}{"points": "many", "tags": primitive.A{"a"}}
+	checked, err := CheckTaskMetadata(fields, map[string]interface{}{"points": "many", "tags": []interface{}{"a"}, "note": "x"}, previous)
+	if err != nil {
+		t.Fatalf("unchanged legacy value: %v", err)
+	}
+	if checked["points"] != "many" || checked["note"] != "x" {
+		t.Errorf("checked = %v", checked)
+	}
+
+	// Changing the legacy value checks it
+	if _, err := CheckTaskMetadata(fields, map[string]interface{}{"points": "more"}, previous); err == nil {
+		t.Error("no error for a changed value of the wrong type")
+	}
+
+	// Removing a required value that was set is still an error
+	if _, err := CheckTaskMetadata(fields, map[string]interface{}{}, map[string]interface{}{"owner": "me"}); err == nil {
+		t.Error("no error for removing a required value")
+	}
+
+	// New tasks check every key
+	if _, err := CheckTaskMetadata(fields, map[string]interface{}{"points": "many"}, nil); err == nil {
+		t.Error("no error for a new task with a value of the wrong type")
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TaskETag func to render the version of a task as a strong entity tag.
+func TaskETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// IfMatch func to check an If-Match header against the version of a task. A
+// missing header or * always matches, weak and malformed tags never do.
+func IfMatch(header string, version int64) bool {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return true
+	}
+
+	etag := TaskETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IfMatchChecksVersion func to tell whether an If-Match header names versions
+// the write has to check. * only asks for the task to exist.
+func IfMatchChecksVersion(header string) bool {
+	header = strings.TrimSpace(header)
+
+	return header != "" && header != "*"
+}
+
+// VersionFilter func to match a task that is still at the given version, so
+// the check happens in the same query as the write. Tasks written before
+// versions existed have none, which counts as version 0.
+func VersionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+
+	return version
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		matches bool
+		checks  bool
+	}{
+		{"", true, false},
+		{"*", true, false},
+		{" * ", true, false},
+		{`"3"`, true, true},
+		{`"1", "3"`, true, true},
+		{`"2"`, false, true},
+		{`W/"3"`, false, true},
+	}
+
+	for _, test := range tests {
+		if got := IfMatch(test.header, 3); got != test.matches {
+			t.Errorf("IfMatch(%q, 3) = %v, want %v", test.header, got, test.matches)
+		}
+		if got := IfMatchChecksVersion(test.header); got != test.checks {
+			t.Errorf("IfMatchChecksVersion(%q) = %v, want %v", test.header, got, test.checks)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"mime"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,4 +20,10 @@ func SetAvatarHeaders(c *fiber.Ctx, buff bytes.Buffer, ext string) error {
 	c.Set("Content-Length", strconv.Itoa(len(buff.Bytes())))
 
 	return c.Next()
-}
\ No newline at end of file
+}
+
+func SetAttachmentHeaders(c *fiber.Ctx, filename, contentType string) {
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Set("X-Content-Type-Options", "nosniff")
+}
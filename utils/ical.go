@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+const (
+	icalTimeFormat   = "20060102T150405Z"
+	icalLineLength   = 75
+	icalProductID    = "-//go-tasks//Tasks//EN"
+	icalUIDDomain    = "@go-tasks"
+	icalRefreshAfter = "PT1H"
+)
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// WriteCalendar func to write tasks as an RFC 5545 calendar of VTODO entries.
+// DTSTAMP is the last update of a task, so the output only changes when the
+// tasks do.
+func WriteCalendar(w io.Writer, name string, tasks []models.Task) error {
+	ical := &icalWriter{w: bufio.NewWriter(w)}
+
+	writeCalendarStart(ical)
+	ical.line("X-WR-CALNAME", icalText(name))
+	ical.line("REFRESH-INTERVAL;VALUE=DURATION", icalRefreshAfter)
+	ical.line("X-PUBLISHED-TTL", icalRefreshAfter)
+
+	for i := range tasks {
+		writeTodo(ical, &tasks[i])
+	}
+
+	ical.line("END", "VCALENDAR")
+
+	return ical.flush()
+}
+
+// WriteTodo func to write a task as a calendar object resource holding only
+// its VTODO, the way CalDAV serves it.
+func WriteTodo(w io.Writer, task *models.Task) error {
+	ical := &icalWriter{w: bufio.NewWriter(w)}
+
+	writeCalendarStart(ical)
+	writeTodo(ical, task)
+	ical.line("END", "VCALENDAR")
+
+	return ical.flush()
+}
+
+// TodoUID func to return the UID of the VTODO of a task, the one its CalDAV
+// client gave it or one made from its ID.
+func TodoUID(task *models.Task) string {
+	if task.ICalUID != "" {
+		return task.ICalUID
+	}
+
+	return task.ID.Hex() + icalUIDDomain
+}
+
+// TodoTaskID func to return the ID of the task a UID was made from, if it was.
+func TodoTaskID(uid string) (primitive.ObjectID, bool) {
+	if !strings.HasSuffix(uid, icalUIDDomain) {
+		return primitive.NilObjectID, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(strings.TrimSuffix(uid, icalUIDDomain))
+
+	return id, err == nil
+}
+
+func writeCalendarStart(ical *icalWriter) {
+	ical.line("BEGIN", "VCALENDAR")
+	ical.line("VERSION", "2.0")
+	ical.line("PRODID", icalProductID)
+	ical.line("CALSCALE", "GREGORIAN")
+}
+
+func writeTodo(ical *icalWriter, task *models.Task) {
+	ical.line("BEGIN", "VTODO")
+	ical.line("UID", icalText(TodoUID(task)))
+	ical.line("DTSTAMP", icalTime(task.UpdatedAt))
+	ical.line("CREATED", icalTime(task.CreatedAt))
+	ical.line("LAST-MODIFIED", icalTime(task.UpdatedAt))
+	ical.line("SEQUENCE", strconv.FormatInt(task.Version, 10))
+	ical.line("SUMMARY", icalText(task.Title))
+
+	if task.StartAt != nil {
+		ical.date("DTSTART", *task.StartAt, task.StartForm, task.TimeZone)
+	}
+	if task.DueAt != nil {
+		ical.date("DUE", *task.DueAt, task.DueForm, task.TimeZone)
+	}
+	if task.Recurrence != "" {
+		ical.line("RRULE", task.Recurrence)
+	}
+	if task.ParentId != nil {
+		ical.line("RELATED-TO", task.ParentId.Hex()+icalUIDDomain)
+	}
+
+	// Tasks do not record when they were completed, their last update is
+	// the closest
+	if task.Completed {
+		ical.line("STATUS", "COMPLETED")
+		ical.line("COMPLETED", icalTime(task.UpdatedAt))
+		ical.line("PERCENT-COMPLETE", "100")
+	} else {
+		ical.line("STATUS", "NEEDS-ACTION")
+	}
+
+	ical.line("END", "VTODO")
+}
+
+// icalWriter writes content lines with CRLF endings, folding them after 75
+// octets without splitting a character.
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (ical *icalWriter) line(name, value string) {
+	line := name + ":" + value
+
+	for len(line) > icalLineLength {
+		cut := icalLineLength
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		ical.write(line[:cut] + "\r\n")
+		// The leading space of a continuation counts towards its length
+		line = " " + line[cut:]
+	}
+
+	ical.write(line + "\r\n")
+}
+
+// date func to write a date property in the form its client gave it. Zoned
+// times name their zone by its IANA ID without a VTIMEZONE, the way clients
+// sharing the tz database resolve them.
+func (ical *icalWriter) date(name string, timestamp int64, form, timeZone string) {
+	at := time.Unix(timestamp, 0).UTC()
+
+	switch form {
+	case models.DateFormDate:
+		ical.line(name+";VALUE=DATE", at.Format("20060102"))
+		return
+	case models.DateFormFloating:
+		ical.line(name, at.Format("20060102T150405"))
+		return
+	case models.DateFormZoned:
+		if zone, err := time.LoadLocation(timeZone); err == nil {
+			ical.line(name+";TZID="+timeZone, at.In(zone).Format("20060102T150405"))
+			return
+		}
+	}
+
+	ical.line(name, icalTime(timestamp))
+}
+
+func (ical *icalWriter) write(s string) {
+	if ical.err == nil {
+		_, ical.err = ical.w.WriteString(s)
+	}
+}
+
+func (ical *icalWriter) flush() error {
+	if ical.err != nil {
+		return ical.err
+	}
+
+	return ical.w.Flush()
+}
+
+func icalText(text string) string {
+	return icalEscaper.Replace(text)
+}
+
+func icalTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format(icalTimeFormat)
+}
@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// ErrNoTodo is returned for iCalendar objects without a VTODO, such as events.
+var ErrNoTodo = errors.New("calendar object holds no VTODO")
+
+// ICalTodo is the part of a VTODO a task keeps. The forms of its dates are
+// those of models.DateFormDate and the like, TimeZone is the TZID of zoned
+// ones.
+type ICalTodo struct {
+	UID        string
+	Summary    string
+	Completed  bool
+	StartAt    *int64
+	DueAt      *int64
+	StartForm  string
+	DueForm    string
+	TimeZone   string
+	Recurrence string
+}
+
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseTodo func to read the VTODO of a calendar object resource, the way a
+// CalDAV client sends it. Overrides of single occurrences, marked by a
+// RECURRENCE-ID, and nested components like VALARM are skipped.
+func ParseTodo(data []byte) (*ICalTodo, error) {
+	var todos []*parsedTodo
+	var components []string
+
+	for _, line := range unfoldICal(string(data)) {
+		property, err := parseICalLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch property.name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(property.value))
+			if len(components) == 2 && components[0] == "VCALENDAR" && components[1] == "VTODO" {
+				todos = append(todos, &parsedTodo{})
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(property.value) {
+				return nil, errors.New("calendar object has unbalanced components")
+			}
+			components = components[:len(components)-1]
+			continue
+		}
+
+		if len(components) == 2 && components[0] == "VCALENDAR" && components[1] == "VTODO" {
+			if err := todos[len(todos)-1].set(property); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(components) > 0 {
+		return nil, errors.New("calendar object has unbalanced components")
+	}
+
+	var todo *parsedTodo
+	for _, parsed := range todos {
+		if todo != nil && parsed.UID != todo.UID {
+			return nil, errors.New("calendar object holds more than one VTODO")
+		}
+		if todo == nil || !parsed.override {
+			todo = parsed
+		}
+	}
+
+	if todo == nil {
+		return nil, ErrNoTodo
+	}
+	if todo.UID == "" {
+		return nil, errors.New("VTODO has no UID")
+	}
+
+	// Clients reopening a task set its status and may leave COMPLETED behind
+	todo.Completed = todo.status == "COMPLETED" || (todo.status == "" && todo.completed)
+
+	return &todo.ICalTodo, nil
+}
+
+type parsedTodo struct {
+	ICalTodo
+	status    string
+	completed bool
+	override  bool
+}
+
+func (todo *parsedTodo) set(property *icalProperty) error {
+	switch property.name {
+	case "UID":
+		todo.UID = unescapeICalText(property.value)
+	case "SUMMARY":
+		todo.Summary = unescapeICalText(property.value)
+	case "STATUS":
+		todo.status = strings.ToUpper(property.value)
+	case "COMPLETED":
+		todo.completed = true
+	case "RECURRENCE-ID":
+		todo.override = true
+	case "RRULE":
+		todo.Recurrence = property.value
+	case "DTSTART", "DUE":
+		timestamp, form, err := parseICalTime(property)
+		if err != nil {
+			return errors.New(property.name + " " + err.Error())
+		}
+		if form == models.DateFormZoned {
+			todo.TimeZone = property.params["TZID"]
+		}
+		if property.name == "DTSTART" {
+			todo.StartAt = &timestamp
+			todo.StartForm = form
+		} else {
+			todo.DueAt = &timestamp
+			todo.DueForm = form
+		}
+	}
+
+	return nil
+}
+
+func unfoldICal(data string) []string {
+	var lines []string
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// parseICalLine func to split a content line into its name, parameters and
+// value. Parameter values may be quoted and hold ; and :.
+func parseICalLine(line string) (*icalProperty, error) {
+	property := &icalProperty{params: map[string]string{}}
+
+	quoted := false
+	start := 0
+	var parts []string
+	for i, char := range line {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == ';' && !quoted:
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case char == ':' && !quoted:
+			parts = append(parts, line[start:i])
+			property.value = line[i+1:]
+			property.name = strings.ToUpper(parts[0])
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(param, "=")
+				property.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			return property, nil
+		}
+	}
+
+	return nil, errors.New("calendar object has a malformed line")
+}
+
+// parseICalTime func to read a DATE or DATE-TIME value as a unix timestamp
+// and the form it was given in. Dates are taken as midnight UTC and floating
+// times as UTC, so both can be written back unchanged. A TZID that is not
+// known makes a floating time.
+func parseICalTime(property *icalProperty) (int64, string, error) {
+	layout, form, location := "20060102T150405", models.DateFormFloating, time.UTC
+
+	switch {
+	case property.params["VALUE"] == "DATE" || len(property.value) == len("20060102"):
+		layout, form = "20060102", models.DateFormDate
+	case strings.HasSuffix(property.value, "Z"):
+		layout, form = icalTimeFormat, ""
+	case property.params["TZID"] != "":
+		if zone, err := time.LoadLocation(property.params["TZID"]); err == nil {
+			form, location = models.DateFormZoned, zone
+		}
+	}
+
+	parsed, err := time.ParseInLocation(layout, property.value, location)
+	if err != nil || parsed.Unix() <= 0 {
+		return 0, "", errors.New("must be a date or a date and time after 1970")
+	}
+
+	return parsed.Unix(), form, nil
+}
+
+func unescapeICalText(text string) string {
+	var unescaped strings.Builder
+
+	escaped := false
+	for _, char := range text {
+		if !escaped && char == '\\' {
+			escaped = true
+			continue
+		}
+		if escaped && (char == 'n' || char == 'N') {
+			char = '\n'
+		}
+		escaped = false
+		unescaped.WriteRune(char)
+	}
+
+	return unescaped.String()
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func ical(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func TestParseTodo(t *testing.T) {
+	todo, err := ParseTodo(ical(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:task-1@example.com",
+		"SUMMARY:Buy milk\\, eggs",
+		"  and bread",
+		"STATUS:NEEDS-ACTION",
+		"DUE:20240301T090000Z",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"BEGIN:VALARM",
+		"UID:alarm",
+		"SUMMARY:Alarm",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if todo.UID != "task-1@example.com" {
+		t.Errorf("UID = %q", todo.UID)
+	}
+	if todo.Summary != "Buy milk, eggs and bread" {
+		t.Errorf("Summary = %q", todo.Summary)
+	}
+	if todo.Completed {
+		t.Error("Completed = true, want false")
+	}
+	if todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("Recurrence = %q", todo.Recurrence)
+	}
+	if want := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC).Unix(); todo.DueAt == nil || *todo.DueAt != want {
+		t.Errorf("DueAt = %v, want %d", todo.DueAt, want)
+	}
+}
+
+func TestParseTodoCompleted(t *testing.T) {
+	tests := []struct {
+		name  string
+		props []string
+		want  bool
+	}{
+		{"status", []string{"STATUS:COMPLETED"}, true},
+		{"completed only", []string{"COMPLETED:20240301T090000Z"}, true},
+		{"reopened", []string{"STATUS:NEEDS-ACTION", "COMPLETED:20240301T090000Z"}, false},
+		{"open", nil, false},
+	}
+
+	for _, test := range tests {
+		lines := append([]string{"BEGIN:VCALENDAR", "BEGIN:VTODO", "UID:1"}, test.props...)
+		lines = append(lines, "END:VTODO", "END:VCALENDAR")
+
+		todo, err := ParseTodo(ical(lines...))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if todo.Completed != test.want {
+			t.Errorf("%s: Completed = %v, want %v", test.name, todo.Completed, test.want)
+		}
+	}
+}
+
+func TestParseTodoTimeZone(t *testing.T) {
+	todo, err := ParseTodo(ical(
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"UID:1",
+		`DTSTART;TZID="Europe/Berlin":20240301T090000`,
+		"END:VTODO",
+		"END:VCALENDAR",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	if want := time.Date(2024, 3, 1, 9, 0, 0, 0, berlin).Unix(); todo.StartAt == nil || *todo.StartAt != want {
+		t.Errorf("StartAt = %v, want %d", todo.StartAt, want)
+	}
+}
+
+func TestParseTodoOverride(t *testing.T) {
+	todo, err := ParseTodo(ical(
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"UID:1",
+		"RECURRENCE-ID:20240308T090000Z",
+		"SUMMARY:Moved occurrence",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"UID:1",
+		"SUMMARY:Series",
+		"END:VTODO",
+		"END:VCALENDAR",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Summary != "Series" {
+		t.Errorf("Summary = %q, want the series", todo.Summary)
+	}
+}
+
+func TestParseTodoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"event", ical("BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:1", "END:VEVENT", "END:VCALENDAR"), ErrNoTodo},
+		{"todo outside a calendar", ical("BEGIN:X", "BEGIN:VTODO", "UID:1", "END:VTODO", "END:X"), ErrNoTodo},
+		{"todo at the top", ical("BEGIN:VTODO", "UID:1", "END:VTODO"), ErrNoTodo},
+		{"unbalanced", ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "UID:1", "END:VCALENDAR"), nil},
+		{"two todos", ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "UID:1", "END:VTODO", "BEGIN:VTODO", "UID:2", "END:VTODO", "END:VCALENDAR"), nil},
+		{"no uid", ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "SUMMARY:x", "END:VTODO", "END:VCALENDAR"), nil},
+		{"malformed line", ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "UID", "END:VTODO", "END:VCALENDAR"), nil},
+		{"bad date", ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "UID:1", "DUE:tomorrow", "END:VTODO", "END:VCALENDAR"), nil},
+	}
+
+	for _, test := range tests {
+		_, err := ParseTodo(test.data)
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		if test.want != nil && err != test.want {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestParseTodoDateForms(t *testing.T) {
+	tests := []struct {
+		name     string
+		property string
+		want     time.Time
+		form     string
+	}{
+		{"utc", "DUE:20240301T090000Z", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), ""},
+		{"date", "DUE;VALUE=DATE:20240301", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), models.DateFormDate},
+		{"bare date", "DUE:20240301", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), models.DateFormDate},
+		{"floating", "DUE:20240301T090000", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), models.DateFormFloating},
+		{"unknown zone", "DUE;TZID=Nowhere/Else:20240301T090000", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), models.DateFormFloating},
+	}
+
+	for _, test := range tests {
+		todo, err := ParseTodo(ical("BEGIN:VCALENDAR", "BEGIN:VTODO", "UID:1", test.property, "END:VTODO", "END:VCALENDAR"))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if todo.DueAt == nil || *todo.DueAt != test.want.Unix() {
+			t.Errorf("%s: DueAt = %v, want %d", test.name, todo.DueAt, test.want.Unix())
+		}
+		if todo.DueForm != test.form {
+			t.Errorf("%s: DueForm = %q, want %q", test.name, todo.DueForm, test.form)
+		}
+		if todo.TimeZone != "" {
+			t.Errorf("%s: TimeZone = %q, want none", test.name, todo.TimeZone)
+		}
+	}
+}
+
+func TestTodoDateFormsRoundTrip(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("no time zone database")
+	}
+
+	sent := ical(
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"UID:1",
+		"DTSTART;TZID=Europe/Berlin:20240301T090000",
+		"DUE;VALUE=DATE:20240302",
+		"END:VTODO",
+		"END:VCALENDAR",
+	)
+	todo, err := ParseTodo(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.StartForm != models.DateFormZoned || todo.TimeZone != "Europe/Berlin" {
+		t.Errorf("StartForm = %q, TimeZone = %q", todo.StartForm, todo.TimeZone)
+	}
+
+	task := &models.Task{
+		ID:        primitive.NewObjectID(),
+		Title:     "Trip",
+		StartAt:   todo.StartAt,
+		DueAt:     todo.DueAt,
+		StartForm: todo.StartForm,
+		DueForm:   todo.DueForm,
+		TimeZone:  todo.TimeZone,
+		ICalUID:   todo.UID,
+	}
+
+	var written bytes.Buffer
+	if err := WriteTodo(&written, task); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"DTSTART;TZID=Europe/Berlin:20240301T090000\r\n", "DUE;VALUE=DATE:20240302\r\n"} {
+		if !strings.Contains(written.String(), line) {
+			t.Errorf("written VTODO lacks %q:\n%s", line, written.String())
+		}
+	}
+
+	again, err := ParseTodo(written.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again.StartAt != *todo.StartAt || *again.DueAt != *todo.DueAt || again.DueForm != todo.DueForm {
+		t.Errorf("read back %+v, want %+v", again, todo)
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A mention is an @ followed by an email address, at the start of the text or
+// after a character that cannot be part of an address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.+\-@])@([\w.%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// ParseMentions func to list the email addresses mentioned as @email in a
+// comment, lower cased and without duplicates.
+func ParseMentions(body string) []string {
+	emails := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(match[1])
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+
+	return emails
+}
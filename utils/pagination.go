@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// SortField struct to describe one key of a listing sort order.
+type SortField struct {
+	Field     string
+	Direction int
+}
+
+// Cursor struct to describe the position of a document in a listing. It keeps
+// the values of every sort field so the next page can be found with a keyset query.
+type Cursor struct {
+	Sort   string             `json:"s"`
+	Values []interface{}      `json:"v"`
+	ID     primitive.ObjectID `json:"id"`
+}
+
+// PageQuery struct to describe the pagination parameters of a request.
+type PageQuery struct {
+	Limit  int
+	Sort   []SortField
+	After  *Cursor
+	Before *Cursor
+}
+
+// SortKey func to render a sort order the way it is written in the query string.
+func SortKey(sort []SortField) string {
+	keys := make([]string, 0, len(sort))
+	for _, field := range sort {
+		if field.Direction < 0 {
+			keys = append(keys, "-"+field.Field)
+		} else {
+			keys = append(keys, field.Field)
+		}
+	}
+
+	return strings.Join(keys, ",")
+}
+
+// EncodeCursor func to turn a cursor into an opaque token.
+func EncodeCursor(cursor Cursor) string {
+	raw, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor func to turn an opaque token back into a cursor.
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	cursor := new(Cursor)
+	if err := json.Unmarshal(raw, cursor); err != nil || cursor.ID.IsZero() {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// NewCursor func to build the cursor of a document for the given sort order.
+func NewCursor(doc interface{}, id primitive.ObjectID, sort []SortField) Cursor {
+	values := make([]interface{}, len(sort))
+
+	raw, err := bson.Marshal(doc)
+	if err == nil {
+		for i, field := range sort {
+			value, err := bson.Raw(raw).LookupErr(strings.Split(field.Field, ".")...)
+			if err != nil {
+				continue
+			}
+
+			var decoded interface{}
+			if err := value.Unmarshal(&decoded); err == nil {
+				values[i] = decoded
+			}
+		}
+	}
+
+	return Cursor{Sort: SortKey(sort), Values: values, ID: id}
+}
+
+// ParsePageQuery func to read limit, after and before from the query string.
+func ParsePageQuery(c *fiber.Ctx, sort []SortField) (*PageQuery, error) {
+	page := &PageQuery{Limit: DefaultPageLimit, Sort: sort}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > MaxPageLimit {
+			return nil, errors.New("limit must be a number between 1 and " + strconv.Itoa(MaxPageLimit))
+		}
+		page.Limit = value
+	}
+
+	after, before := c.Query("after"), c.Query("before")
+	if after != "" && before != "" {
+		return nil, errors.New("after and before cannot be used together")
+	}
+
+	for key, token := range map[string]string{"after": after, "before": before} {
+		if token == "" {
+			continue
+		}
+
+		cursor, err := DecodeCursor(token)
+		if err != nil {
+			return nil, err
+		}
+
+		// A cursor only makes sense for the sort order it was created with
+		if cursor.Sort != SortKey(sort) || len(cursor.Values) != len(sort) {
+			return nil, errors.New("Cursor does not match the requested sort order")
+		}
+
+		if key == "after" {
+			page.After = cursor
+		} else {
+			page.Before = cursor
+		}
+	}
+
+	return page, nil
+}
+
+// SortDocument func to build the Mongo sort for the page. Pages read with
+// before walk the listing backwards, so every direction is flipped.
+func (page *PageQuery) SortDocument() bson.D {
+	sort := bson.D{}
+	for _, field := range page.Sort {
+		sort = append(sort, bson.E{Key: field.Field, Value: field.Direction * page.flip()})
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: -1 * page.flip()})
+}
+
+// CursorFilter func to build the keyset condition selecting what comes after
+// (or before) the cursor in the page sort order.
+func (page *PageQuery) CursorFilter() bson.M {
+	cursor := page.After
+	if cursor == nil {
+		cursor = page.Before
+	}
+	if cursor == nil {
+		return bson.M{}
+	}
+
+	branches := bson.A{}
+	equal := bson.A{}
+
+	for i, field := range page.Sort {
+		if next := keysetAfter(field.Field, cursor.Values[i], field.Direction*page.flip()); next != nil {
+			branches = append(branches, bson.M{"$and": append(append(bson.A{}, equal...), next)})
+		}
+		equal = append(equal, bson.M{field.Field: cursor.Values[i]})
+	}
+
+	idOperator := "$lt"
+	if page.flip() < 0 {
+		idOperator = "$gt"
+	}
+	branches = append(branches, bson.M{"$and": append(equal, bson.M{"_id": bson.M{idOperator: cursor.ID}})})
+
+	return bson.M{"$or": branches}
+}
+
+func (page *PageQuery) flip() int {
+	if page.Before != nil {
+		return -1
+	}
+
+	return 1
+}
+
+// keysetAfter func to select values strictly after value in the given direction.
+// Mongo sorts missing and null values lowest, so they need their own handling.
+func keysetAfter(field string, value interface{}, direction int) bson.M {
+	if value == nil {
+		if direction > 0 {
+			return bson.M{field: bson.M{"$ne": nil}}
+		}
+		return nil
+	}
+
+	if direction > 0 {
+		return bson.M{field: bson.M{"$gt": value}}
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$lt": value}},
+		bson.M{field: nil},
+	}}
+}
+
+// PageLink func to build a link to another page, keeping every other query parameter.
+func PageLink(c *fiber.Ctx, limit int, key string, cursor Cursor) string {
+	query := url.Values{}
+	for k, v := range QueryParams(c) {
+		query.Set(k, v)
+	}
+
+	query.Del("after")
+	query.Del("before")
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set(key, EncodeCursor(cursor))
+
+	return c.Path() + "?" + query.Encode()
+}
+
+// QueryParams func to collect the query string into a map.
+func QueryParams(c *fiber.Ctx) map[string]string {
+	params := map[string]string{}
+	c.Request().URI().QueryArgs().VisitAll(func(k, v []byte) {
+		params[string(k)] = string(v)
+	})
+
+	return params
+}
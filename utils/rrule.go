@@ -0,0 +1,362 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRRulePeriods bounds the expansion of rules that rarely or never match,
+// like BYMONTHDAY=31 every other February. Periods before the time asked
+// about do not count towards it, so long running series do not end.
+const maxRRulePeriods = 5000
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// RRuleDay struct to describe a BYDAY entry, N is the optional nth weekday
+// of the month (2TU, -1FR) and zero for every matching weekday.
+type RRuleDay struct {
+	N   int
+	Day time.Weekday
+}
+
+// RRule struct to describe the supported subset of an RFC 5545 recurrence rule:
+// DAILY, WEEKLY and MONTHLY with INTERVAL, COUNT, UNTIL, BYDAY and BYMONTHDAY.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RRuleDay
+	ByMonthDay []int
+}
+
+// ParseRRule func to parse a rule like FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10.
+func ParseRRule(rule string) (*RRule, error) {
+	rrule := &RRule{Interval: 1}
+	seen := map[string]bool{}
+
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("Recurrence rule is empty")
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+		if !found || value == "" {
+			return nil, errors.New("Invalid recurrence rule part: " + part)
+		}
+		if seen[key] {
+			return nil, errors.New("Duplicate recurrence rule part: " + key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return nil, errors.New("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+			rrule.Freq = value
+
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+			rrule.Interval = interval
+
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+			rrule.Count = count
+
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return nil, err
+			}
+			rrule.Until = &until
+
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				byDay, err := parseRRuleDay(day)
+				if err != nil {
+					return nil, err
+				}
+				rrule.ByDay = append(rrule.ByDay, byDay)
+			}
+
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return nil, errors.New("Invalid BYMONTHDAY: " + day)
+				}
+				rrule.ByMonthDay = append(rrule.ByMonthDay, monthDay)
+			}
+
+		case "WKST":
+			if value != "MO" {
+				return nil, errors.New("Only WKST=MO is supported")
+			}
+
+		default:
+			return nil, errors.New("Unsupported recurrence rule part: " + key)
+		}
+	}
+
+	if rrule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rrule.Count > 0 && rrule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be used together")
+	}
+	if len(rrule.ByMonthDay) > 0 && rrule.Freq != "MONTHLY" {
+		return nil, errors.New("BYMONTHDAY needs FREQ=MONTHLY")
+	}
+	for _, day := range rrule.ByDay {
+		if day.N != 0 && rrule.Freq != "MONTHLY" {
+			return nil, errors.New("Numbered BYDAY needs FREQ=MONTHLY")
+		}
+	}
+
+	return rrule, nil
+}
+
+func parseRRuleDay(value string) (RRuleDay, error) {
+	if len(value) < 2 {
+		return RRuleDay{}, errors.New("Invalid BYDAY: " + value)
+	}
+
+	weekday, ok := rruleWeekdays[value[len(value)-2:]]
+	if !ok {
+		return RRuleDay{}, errors.New("Invalid BYDAY: " + value)
+	}
+
+	n := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		number, err := strconv.Atoi(prefix)
+		if err != nil || number == 0 || number < -5 || number > 5 {
+			return RRuleDay{}, errors.New("Invalid BYDAY: " + value)
+		}
+		n = number
+	}
+
+	return RRuleDay{N: n, Day: weekday}, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if until, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			return until, nil
+		}
+	}
+
+	return time.Time{}, errors.New("Invalid UNTIL: " + value)
+}
+
+// Occurrences func to list up to n occurrences strictly after the given time
+// for a series starting at start. The start itself is always the first
+// occurrence and counts towards COUNT. Days and times of day follow the
+// location of start.
+func (rrule *RRule) Occurrences(start, after time.Time, n int) []time.Time {
+	occurrences := []time.Time{}
+	emitted := 0
+
+	emit := func(occurrence time.Time) bool {
+		if rrule.Until != nil && occurrence.After(*rrule.Until) {
+			return false
+		}
+		emitted++
+		if rrule.Count > 0 && emitted > rrule.Count {
+			return false
+		}
+		if occurrence.After(after) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < n
+	}
+
+	if !emit(start) {
+		return occurrences
+	}
+
+	// Without COUNT nothing before the time asked about matters, with it every
+	// earlier occurrence has to be counted
+	first := rrule.firstPeriod(start, after)
+	period := first
+	if rrule.Count > 0 {
+		period = 0
+	}
+
+	for ; period < first+maxRRulePeriods; period++ {
+		for _, candidate := range rrule.periodCandidates(start, period) {
+			if !candidate.After(start) {
+				continue
+			}
+			if !emit(candidate) {
+				return occurrences
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// Next func to return the first occurrence after the given time, or false when
+// the series is over.
+func (rrule *RRule) Next(start, after time.Time) (time.Time, bool) {
+	occurrences := rrule.Occurrences(start, after, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+
+	return occurrences[0], true
+}
+
+// firstPeriod func to return the period just before the one holding the
+// given time, the earliest one that can have occurrences after it.
+func (rrule *RRule) firstPeriod(start, after time.Time) int {
+	after = after.In(start.Location())
+	periods := 0
+
+	switch rrule.Freq {
+	case "DAILY":
+		periods = civilDays(start, after) / rrule.Interval
+	case "WEEKLY":
+		periods = (civilDays(start, after) + (int(start.Weekday())+6)%7) / 7 / rrule.Interval
+	case "MONTHLY":
+		months := (after.Year()-start.Year())*12 + int(after.Month()) - int(start.Month())
+		periods = months / rrule.Interval
+	}
+
+	if periods < 1 {
+		return 0
+	}
+
+	return periods - 1
+}
+
+// civilDays func to count the calendar days from one date to another,
+// regardless of daylight saving changes in between.
+func civilDays(from, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+// periodCandidates func to list the matching days of one DAILY, WEEKLY or
+// MONTHLY period, in order and at the time of day of start.
+func (rrule *RRule) periodCandidates(start time.Time, period int) []time.Time {
+	step := period * rrule.Interval
+	candidates := []time.Time{}
+
+	switch rrule.Freq {
+	case "DAILY":
+		day := start.AddDate(0, 0, step)
+		if rrule.matchesWeekday(day) {
+			candidates = append(candidates, day)
+		}
+
+	case "WEEKLY":
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		for offset := 0; offset < 7; offset++ {
+			day := monday.AddDate(0, 0, offset)
+			matches := rrule.matchesWeekday(day)
+			if len(rrule.ByDay) == 0 {
+				matches = day.Weekday() == start.Weekday()
+			}
+			if matches {
+				candidates = append(candidates, day)
+			}
+		}
+
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		days := daysIn(first)
+
+		matches := map[int]bool{}
+		for _, monthDay := range rrule.ByMonthDay {
+			if monthDay < 0 {
+				monthDay = days + monthDay + 1
+			}
+			if monthDay >= 1 && monthDay <= days {
+				matches[monthDay] = true
+			}
+		}
+		for _, byDay := range rrule.ByDay {
+			for _, monthDay := range weekdaysInMonth(first, byDay) {
+				matches[monthDay] = true
+			}
+		}
+		if len(rrule.ByMonthDay) == 0 && len(rrule.ByDay) == 0 && start.Day() <= days {
+			matches[start.Day()] = true
+		}
+
+		monthDays := make([]int, 0, len(matches))
+		for monthDay := range matches {
+			monthDays = append(monthDays, monthDay)
+		}
+		sort.Ints(monthDays)
+
+		for _, monthDay := range monthDays {
+			candidates = append(candidates, first.AddDate(0, 0, monthDay-1))
+		}
+	}
+
+	return candidates
+}
+
+func (rrule *RRule) matchesWeekday(day time.Time) bool {
+	if len(rrule.ByDay) == 0 {
+		return true
+	}
+
+	for _, byDay := range rrule.ByDay {
+		if byDay.Day == day.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysIn(first time.Time) int {
+	return first.AddDate(0, 1, -1).Day()
+}
+
+// weekdaysInMonth func to list the days of the month matching a BYDAY entry.
+func weekdaysInMonth(first time.Time, byDay RRuleDay) []int {
+	days := []int{}
+	for day := 1 + (int(byDay.Day)-int(first.Weekday())+7)%7; day <= daysIn(first); day += 7 {
+		days = append(days, day)
+	}
+
+	if byDay.N == 0 {
+		return days
+	}
+
+	index := byDay.N - 1
+	if byDay.N < 0 {
+		index = len(days) + byDay.N
+	}
+	if index < 0 || index >= len(days) {
+		return []int{}
+	}
+
+	return []int{days[index]}
+}
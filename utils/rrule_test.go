@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func mustParseRRule(t *testing.T, rule string) *RRule {
+	t.Helper()
+
+	rrule, err := ParseRRule(rule)
+	if err != nil {
+		t.Fatalf("ParseRRule(%q): %v", rule, err)
+	}
+
+	return rrule
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+
+	return location
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		if _, err := ParseRRule(rule); err == nil {
+			t.Errorf("ParseRRule(%q) did not fail", rule)
+		}
+	}
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		n    int
+		want []string
+	}{
+		{"FREQ=DAILY;INTERVAL=2", 3, []string{"2024-02-02", "2024-02-04", "2024-02-06"}},
+		{"FREQ=DAILY;COUNT=3", 5, []string{"2024-02-01", "2024-02-02"}},
+		{"FREQ=DAILY;UNTIL=20240202", 5, []string{"2024-02-01", "2024-02-02"}},
+		{"FREQ=WEEKLY;BYDAY=MO,FR", 3, []string{"2024-02-02", "2024-02-05", "2024-02-09"}},
+		{"FREQ=MONTHLY", 3, []string{"2024-03-31", "2024-05-31", "2024-07-31"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", 3, []string{"2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"FREQ=MONTHLY;BYDAY=2TU", 2, []string{"2024-02-13", "2024-03-12"}},
+	}
+
+	for _, test := range tests {
+		occurrences := mustParseRRule(t, test.rule).Occurrences(start, start, test.n)
+
+		got := []string{}
+		for _, occurrence := range occurrences {
+			got = append(got, occurrence.Format("2006-01-02"))
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: occurrences = %v, want %v", test.rule, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: occurrences = %v, want %v", test.rule, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRRuleOccurrencesLongRunning(t *testing.T) {
+	start := time.Date(2010, time.March, 1, 8, 0, 0, 0, time.UTC)
+	after := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		want time.Time
+	}{
+		{"FREQ=DAILY", time.Date(2026, time.October, 18, 8, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;BYDAY=MO", time.Date(2026, time.October, 19, 8, 0, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY", time.Date(2026, time.November, 1, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		next, ok := mustParseRRule(t, test.rule).Next(start, after)
+		if !ok || !next.Equal(test.want) {
+			t.Errorf("%s: Next = %v, %v, want %v", test.rule, next, ok, test.want)
+		}
+	}
+
+	// Occurrences before the time asked about still count towards COUNT
+	if next, ok := mustParseRRule(t, "FREQ=DAILY;COUNT=6000").Next(start, after); ok {
+		t.Errorf("COUNT=6000: Next = %v, want the series to be over", next)
+	}
+}
+
+func TestTaskOccurrencesTimeZone(t *testing.T) {
+	sydney := mustLoadLocation(t, "Australia/Sydney")
+
+	// Monday 09:00 in Sydney is Sunday 22:00 in UTC
+	dueAt := time.Date(2024, time.March, 25, 9, 0, 0, 0, sydney).Unix()
+	task := &models.Task{DueAt: &dueAt, TimeZone: "Australia/Sydney", Recurrence: "FREQ=WEEKLY;BYDAY=MO"}
+
+	occurrences, err := TaskOccurrences(task, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Daylight saving ends on April 7th, the time of day stays at 09:00
+	want := []time.Time{
+		time.Date(2024, time.April, 1, 9, 0, 0, 0, sydney),
+		time.Date(2024, time.April, 8, 9, 0, 0, 0, sydney),
+		time.Date(2024, time.April, 15, 9, 0, 0, 0, sydney),
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("occurrences = %v, want %v", occurrences, want)
+	}
+	for i, occurrence := range occurrences {
+		if got := time.Unix(occurrence, 0).In(sydney); !got.Equal(want[i]) {
+			t.Errorf("occurrence %d = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// All-day dates keep their date in UTC whatever the time zone of the task
+	task.DueForm = models.DateFormDate
+	if location := RecurrenceLocation(task); location != time.UTC {
+		t.Errorf("RecurrenceLocation of an all-day task = %v, want UTC", location)
+	}
+}
+
+func TestNextTaskOccurrence(t *testing.T) {
+	id := primitive.NewObjectID()
+	startAt := time.Date(2024, time.January, 1, 8, 0, 0, 0, time.UTC).Unix()
+	dueAt := startAt + 3600
+	task := &models.Task{ID: id, StartAt: &startAt, DueAt: &dueAt, Recurrence: "FREQ=DAILY", TimeZone: "UTC"}
+
+	next, err := NextTaskOccurrence(task)
+	if err != nil || next == nil {
+		t.Fatalf("NextTaskOccurrence = %v, %v", next, err)
+	}
+
+	if *next.DueAt != dueAt+86400 || *next.StartAt != startAt+86400 {
+		t.Errorf("dates = %d, %d", *next.StartAt, *next.DueAt)
+	}
+	if next.OccurrenceAt == nil || *next.OccurrenceAt != *next.DueAt {
+		t.Errorf("OccurrenceAt = %v, want %d", next.OccurrenceAt, *next.DueAt)
+	}
+	if next.SeriesId == nil || *next.SeriesId != id || *next.SeriesStart != dueAt {
+		t.Errorf("series = %v from %v", next.SeriesId, next.SeriesStart)
+	}
+	if next.TimeZone != "UTC" {
+		t.Errorf("TimeZone = %q", next.TimeZone)
+	}
+
+	// A finished series has no next occurrence
+	task.Recurrence = "FREQ=DAILY;COUNT=1"
+	if next, err := NextTaskOccurrence(task); err != nil || next != nil {
+		t.Errorf("NextTaskOccurrence of a finished series = %v, %v", next, err)
+	}
+}
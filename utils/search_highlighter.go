@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	snippetLength  = 160
+	snippetContext = 60
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+// SearchQuery struct to describe the parts of a Mongo $text search string.
+type SearchQuery struct {
+	Terms   []string
+	Phrases []string
+	Negated []string
+}
+
+// ParseSearchQuery func to split a search string into terms, "quoted phrases"
+// and -negated words, following the rules of Mongo $text search.
+func ParseSearchQuery(q string) *SearchQuery {
+	query := &SearchQuery{}
+
+	for len(q) > 0 {
+		q = strings.TrimLeft(q, " \t")
+		if q == "" {
+			break
+		}
+
+		negated := strings.HasPrefix(q, "-")
+		if negated {
+			q = q[1:]
+		}
+
+		var token string
+		phrase := strings.HasPrefix(q, "\"")
+		if phrase {
+			end := strings.Index(q[1:], "\"")
+			if end < 0 {
+				token, q = q[1:], ""
+			} else {
+				token, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(q, " \t")
+			if end < 0 {
+				token, q = q, ""
+			} else {
+				token, q = q[:end], q[end:]
+			}
+		}
+
+		token = strings.TrimSpace(token)
+		switch {
+		case token == "":
+		case negated:
+			query.Negated = append(query.Negated, token)
+		case phrase:
+			query.Phrases = append(query.Phrases, token)
+		default:
+			query.Terms = append(query.Terms, token)
+		}
+	}
+
+	return query
+}
+
+// IsEmpty func to report whether the query has anything to match on.
+func (query *SearchQuery) IsEmpty() bool {
+	return len(query.Terms) == 0 && len(query.Phrases) == 0
+}
+
+// Highlight func to return a snippet of text with every match wrapped in
+// <mark> tags, or false when nothing in the text matches. The text itself is
+// HTML escaped so the snippet is safe to render. Terms match the start of
+// words so stemmed matches like "plan" in "planning" are found too.
+func (query *SearchQuery) Highlight(text string) (string, bool) {
+	pattern := query.pattern()
+	if pattern == nil {
+		return "", false
+	}
+
+	matches := pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	if utf8.RuneCountInString(text) > snippetLength {
+		start = runeOffset(text, matches[0][0], -snippetContext)
+		end = runeOffset(text, matches[0][1], snippetLength-snippetContext)
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("...")
+	}
+
+	position := start
+	for _, match := range matches {
+		if match[0] < position || match[1] > end {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(text[position:match[0]]))
+		snippet.WriteString(highlightOpen + html.EscapeString(text[match[0]:match[1]]) + highlightClose)
+		position = match[1]
+	}
+	snippet.WriteString(html.EscapeString(text[position:end]))
+
+	if end < len(text) {
+		snippet.WriteString("...")
+	}
+
+	return snippet.String(), true
+}
+
+func (query *SearchQuery) pattern() *regexp.Regexp {
+	parts := []string{}
+	for _, phrase := range query.Phrases {
+		parts = append(parts, regexp.QuoteMeta(phrase))
+	}
+	for _, term := range query.Terms {
+		parts = append(parts, `\b`+regexp.QuoteMeta(term)+`\w*`)
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+
+	// Longest alternatives first so phrases win over the words inside them
+	sort.Slice(parts, func(i, j int) bool { return len(parts[i]) > len(parts[j]) })
+
+	return regexp.MustCompile("(?i)" + strings.Join(parts, "|"))
+}
+
+// runeOffset func to move a byte offset by a number of runes, staying inside text.
+func runeOffset(text string, offset, runes int) int {
+	for runes < 0 && offset > 0 {
+		_, size := utf8.DecodeLastRuneInString(text[:offset])
+		offset -= size
+		runes++
+	}
+	for runes > 0 && offset < len(text) {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+		runes--
+	}
+
+	return offset
+}
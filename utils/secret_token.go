@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewSecretToken func to generate a secret for calendar feeds and app
+// passwords, and the hash that is stored for it. The secrets are random
+// enough that a plain hash is safe to look them up by.
+func NewSecretToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	return token, SecretTokenHash(token), nil
+}
+
+// SecretTokenHash func to hash a secret for lookups.
+func SecretTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
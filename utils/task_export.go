@@ -0,0 +1,546 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// File extensions of the formats tasks can be exported to and imported from
+var TaskExportFormats = map[string]string{
+	"csv":      ".csv",
+	"json":     ".json",
+	"todotxt":  ".txt",
+	"markdown": ".md",
+}
+
+// Columns of a CSV export, metadata is one column of JSON so its values keep their types
+var taskCSVColumns = []string{"title", "completed", "start_at", "due_at", "recurrence", "metadata", "created_at", "updated_at"}
+
+var (
+	todoTagPattern      = regexp.MustCompile(`^[^\s:]+:\S+$`)
+	todoDatePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	markdownTaskPattern = regexp.MustCompile(`^\s*[-*+] \[([ xX])\] (.*)$`)
+)
+
+const todoDateLayout = "2006-01-02"
+
+// ImportRow struct to describe one task read from an import. Row is the
+// position of the task in the file: the line for todo.txt and Markdown, the
+// record for CSV (the header being 1) and the index from 1 for JSON.
+type ImportRow struct {
+	Row  int
+	Task *models.PortableTask
+	Err  error
+}
+
+// PortableTaskOf func to take the fields a task keeps across an export and
+// import.
+func PortableTaskOf(task *models.Task) models.PortableTask {
+	return models.PortableTask{
+		Title:      task.Title,
+		Completed:  task.Completed,
+		Metadata:   task.Metadata,
+		StartAt:    task.StartAt,
+		DueAt:      task.DueAt,
+		Recurrence: task.Recurrence,
+		CreatedAt:  task.CreatedAt,
+		UpdatedAt:  task.UpdatedAt,
+	}
+}
+
+// WriteTasks func to export tasks in one of the TaskExportFormats.
+func WriteTasks(w io.Writer, format string, tasks []models.PortableTask) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tasks)
+
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(taskCSVColumns); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			record, err := taskCSVRecord(task)
+			if err != nil {
+				return err
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case "todotxt":
+		for _, task := range tasks {
+			line, err := todoLine(task)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "markdown":
+		if _, err := io.WriteString(w, "# Tasks\n\n"); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			line, err := markdownLine(task)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return errors.New("format must be one of csv, json, todotxt or markdown")
+}
+
+// ReadTasks func to read the tasks of an import. Problems with a single task
+// are kept on its row, an error is only returned when the file can not be
+// read at all.
+func ReadTasks(r io.Reader, format string) ([]ImportRow, error) {
+	switch format {
+	case "json":
+		var raws []json.RawMessage
+		if err := json.NewDecoder(r).Decode(&raws); err != nil {
+			return nil, errors.New("JSON imports must be an array of tasks: " + err.Error())
+		}
+		rows := make([]ImportRow, 0, len(raws))
+		for i, raw := range raws {
+			task := new(models.PortableTask)
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(task)
+			rows = append(rows, importRow(i+1, task, err))
+		}
+		return rows, nil
+
+	case "csv":
+		return readTaskCSV(r)
+
+	case "todotxt", "markdown":
+		rows := []ImportRow{}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimRight(scanner.Text(), "\r")
+			if format == "todotxt" && strings.TrimSpace(text) != "" {
+				task, err := parseTodoLine(text)
+				rows = append(rows, importRow(line, task, err))
+			}
+			if format == "markdown" {
+				if match := markdownTaskPattern.FindStringSubmatch(text); match != nil {
+					task, err := parseMarkdownTask(match[1] != " ", match[2])
+					rows = append(rows, importRow(line, task, err))
+				}
+			}
+		}
+		return rows, scanner.Err()
+	}
+
+	return nil, errors.New("format must be one of csv, json, todotxt or markdown")
+}
+
+// importRow func to check what every format needs of an imported task. Tasks
+// without timestamps keep them zero, they are new as of their import.
+func importRow(row int, task *models.PortableTask, err error) ImportRow {
+	if err == nil {
+		switch {
+		case strings.TrimSpace(task.Title) == "":
+			err = errors.New("title is required")
+		case task.StartAt != nil && *task.StartAt <= 0, task.DueAt != nil && *task.DueAt <= 0:
+			err = errors.New("start_at and due_at must be unix timestamps")
+		case task.CreatedAt < 0 || task.UpdatedAt < 0:
+			err = errors.New("created_at and updated_at must be unix timestamps")
+		}
+	}
+	if err != nil {
+		return ImportRow{Row: row, Err: err}
+	}
+
+	return ImportRow{Row: row, Task: task}
+}
+
+func taskCSVRecord(task models.PortableTask) ([]string, error) {
+	metadata := ""
+	if len(task.Metadata) > 0 {
+		raw, err := json.Marshal(task.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = string(raw)
+	}
+
+	return []string{
+		task.Title,
+		strconv.FormatBool(task.Completed),
+		formatTimestamp(task.StartAt),
+		formatTimestamp(task.DueAt),
+		task.Recurrence,
+		metadata,
+		strconv.FormatInt(task.CreatedAt, 10),
+		strconv.FormatInt(task.UpdatedAt, 10),
+	}, nil
+}
+
+func readTaskCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV imports need a header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		known := false
+		for _, column := range taskCSVColumns {
+			known = known || name == column
+		}
+		if !known {
+			return nil, errors.New("Unknown CSV column: " + name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("CSV imports need a title column")
+	}
+
+	rows := []ImportRow{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, err
+			}
+			rows = append(rows, ImportRow{Row: row, Err: err})
+			continue
+		}
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		task, err := parseTaskCSVRecord(value)
+		rows = append(rows, importRow(row, task, err))
+	}
+}
+
+func parseTaskCSVRecord(value func(string) string) (*models.PortableTask, error) {
+	task := &models.PortableTask{Title: value("title"), Recurrence: value("recurrence")}
+
+	if completed := value("completed"); completed != "" {
+		flag, err := strconv.ParseBool(completed)
+		if err != nil {
+			return nil, errors.New("completed must be true or false")
+		}
+		task.Completed = flag
+	}
+
+	var err error
+	if task.StartAt, err = parseTimestamp("start_at", value("start_at")); err != nil {
+		return nil, err
+	}
+	if task.DueAt, err = parseTimestamp("due_at", value("due_at")); err != nil {
+		return nil, err
+	}
+
+	for column, target := range map[string]*int64{"created_at": &task.CreatedAt, "updated_at": &task.UpdatedAt} {
+		timestamp, err := parseTimestamp(column, value(column))
+		if err != nil {
+			return nil, err
+		}
+		if timestamp != nil {
+			*target = *timestamp
+		}
+	}
+
+	if metadata := value("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &task.Metadata); err != nil {
+			return nil, errors.New("metadata must be a JSON object")
+		}
+	}
+
+	return task, nil
+}
+
+// todoLine func to write a task as a todo.txt line. Exact timestamps, the
+// rule and metadata are key:value tags, metadata values that are not plain
+// strings, and empty strings, are written as JSON.
+func todoLine(task models.PortableTask) (string, error) {
+	parts := []string{}
+	if task.Completed {
+		parts = append(parts, "x", time.Unix(task.UpdatedAt, 0).UTC().Format(todoDateLayout))
+	}
+	parts = append(parts, time.Unix(task.CreatedAt, 0).UTC().Format(todoDateLayout))
+
+	for _, word := range strings.Split(task.Title, " ") {
+		parts = append(parts, escapeTodoWord(word))
+	}
+
+	if task.StartAt != nil {
+		parts = append(parts, "start_at:"+formatTimestamp(task.StartAt))
+	}
+	if task.DueAt != nil {
+		parts = append(parts, "due_at:"+formatTimestamp(task.DueAt))
+	}
+	if task.Recurrence != "" {
+		parts = append(parts, "rrule:"+url.PathEscape(task.Recurrence))
+	}
+	for _, key := range sortedKeys(task.Metadata) {
+		value, err := todoValue(task.Metadata[key])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "meta."+key+":"+value)
+	}
+	parts = append(parts, "created_at:"+strconv.FormatInt(task.CreatedAt, 10), "updated_at:"+strconv.FormatInt(task.UpdatedAt, 10))
+
+	return strings.Join(parts, " "), nil
+}
+
+// parseTodoLine func to read a todo.txt line. Lines written by other tools
+// have dates instead of timestamps and due:YYYY-MM-DD for the due date.
+func parseTodoLine(line string) (*models.PortableTask, error) {
+	task := new(models.PortableTask)
+	words := strings.Split(line, " ")
+
+	if len(words) > 0 && words[0] == "x" {
+		task.Completed = true
+		words = words[1:]
+		if len(words) > 1 && todoDatePattern.MatchString(words[0]) && todoDatePattern.MatchString(words[1]) {
+			words = words[1:]
+		}
+	}
+	if len(words) > 0 && todoDatePattern.MatchString(words[0]) {
+		created, err := time.Parse(todoDateLayout, words[0])
+		if err != nil {
+			return nil, errors.New("Invalid creation date: " + words[0])
+		}
+		task.CreatedAt = created.Unix()
+		words = words[1:]
+	}
+
+	title := []string{}
+	for _, word := range words {
+		if !todoTagPattern.MatchString(word) {
+			if unescaped, err := url.PathUnescape(word); err == nil {
+				word = unescaped
+			}
+			title = append(title, word)
+			continue
+		}
+
+		key, value, _ := strings.Cut(word, ":")
+		var err error
+		switch {
+		case key == "start_at":
+			task.StartAt, err = parseTimestamp(key, value)
+		case key == "due_at":
+			task.DueAt, err = parseTimestamp(key, value)
+		case key == "due" && task.DueAt == nil:
+			var due time.Time
+			if due, err = time.Parse(todoDateLayout, value); err == nil {
+				timestamp := due.Unix()
+				task.DueAt = &timestamp
+			}
+		case key == "created_at", key == "updated_at":
+			var timestamp *int64
+			if timestamp, err = parseTimestamp(key, value); err == nil {
+				if key == "created_at" {
+					task.CreatedAt = *timestamp
+				} else {
+					task.UpdatedAt = *timestamp
+				}
+			}
+		case key == "rrule":
+			task.Recurrence, err = url.PathUnescape(value)
+		case strings.HasPrefix(key, "meta."):
+			if task.Metadata == nil {
+				task.Metadata = map[string]interface{}{}
+			}
+			task.Metadata[strings.TrimPrefix(key, "meta.")], err = parseTodoValue(value)
+		default:
+			// Tags of other tools stay part of the title
+			title = append(title, word)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %v", key, err)
+		}
+	}
+	task.Title = strings.Join(title, " ")
+
+	return task, nil
+}
+
+// escapeTodoWord func to keep a word of a title from being read as a tag or
+// from breaking the line.
+func escapeTodoWord(word string) string {
+	word = strings.NewReplacer("%", "%25", "\n", "%0A", "\r", "%0D").Replace(word)
+	if todoTagPattern.MatchString(word) {
+		word = strings.ReplaceAll(word, ":", "%3A")
+	}
+
+	return word
+}
+
+func todoValue(value interface{}) (string, error) {
+	text, ok := value.(string)
+	// An empty value would not be read back as a tag, "" is
+	if !ok || text == "" || json.Valid([]byte(text)) {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		text = string(raw)
+	}
+
+	return url.PathEscape(text), nil
+}
+
+func parseTodoValue(value string) (interface{}, error) {
+	text, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid([]byte(text)) {
+		return text, nil
+	}
+
+	var decoded interface{}
+	err = json.Unmarshal([]byte(text), &decoded)
+	return decoded, err
+}
+
+// markdownDetails struct to describe what a Markdown task keeps in the
+// comment after its title. The title is only repeated when it does not fit on
+// the line.
+type markdownDetails struct {
+	Title      string                 `json:"title,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	StartAt    *int64                 `json:"start_at,omitempty"`
+	DueAt      *int64                 `json:"due_at,omitempty"`
+	Recurrence string                 `json:"recurrence,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	UpdatedAt  int64                  `json:"updated_at"`
+}
+
+func markdownLine(task models.PortableTask) (string, error) {
+	details := markdownDetails{
+		Metadata:   task.Metadata,
+		StartAt:    task.StartAt,
+		DueAt:      task.DueAt,
+		Recurrence: task.Recurrence,
+		CreatedAt:  task.CreatedAt,
+		UpdatedAt:  task.UpdatedAt,
+	}
+
+	title := task.Title
+	if strings.ContainsAny(title, "\r\n") || strings.Contains(title, "<!--") || strings.TrimSpace(title) != title {
+		details.Title = title
+		title = strings.Join(strings.Fields(title), " ")
+	}
+
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return "", err
+	}
+
+	checkbox := "[ ]"
+	if task.Completed {
+		checkbox = "[x]"
+	}
+
+	// JSON escapes <, > and & so the comment can not end early
+	return "- " + checkbox + " " + title + " <!-- " + string(raw) + " -->", nil
+}
+
+func parseMarkdownTask(completed bool, text string) (*models.PortableTask, error) {
+	task := &models.PortableTask{Title: strings.TrimSpace(text), Completed: completed}
+
+	start := strings.LastIndex(text, "<!--")
+	if start < 0 || !strings.HasSuffix(strings.TrimSpace(text), "-->") {
+		return task, nil
+	}
+
+	details := new(markdownDetails)
+	comment := strings.TrimSuffix(strings.TrimSpace(text[start+len("<!--"):]), "-->")
+	if err := json.Unmarshal([]byte(comment), details); err != nil {
+		return nil, errors.New("Invalid task details: " + err.Error())
+	}
+
+	task.Title = strings.TrimSpace(text[:start])
+	if details.Title != "" {
+		task.Title = details.Title
+	}
+	task.Metadata = details.Metadata
+	task.StartAt = details.StartAt
+	task.DueAt = details.DueAt
+	task.Recurrence = details.Recurrence
+	task.CreatedAt = details.CreatedAt
+	task.UpdatedAt = details.UpdatedAt
+
+	return task, nil
+}
+
+func formatTimestamp(timestamp *int64) string {
+	if timestamp == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*timestamp, 10)
+}
+
+func parseTimestamp(name, value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || timestamp <= 0 {
+		return nil, errors.New(name + " must be a unix timestamp")
+	}
+
+	return &timestamp, nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+func exportTestTasks() []models.PortableTask {
+	startAt := int64(1704096000)
+	dueAt := int64(1704182400)
+
+	return []models.PortableTask{
+		{
+			Title:      "Plan: the 100% offsite",
+			Completed:  true,
+			StartAt:    &startAt,
+			DueAt:      &dueAt,
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO,FR",
+			Metadata: map[string]interface{}{
+				"empty":  "",
+				"note":   "call back later",
+				"quoted": "42",
+				"points": float64(3),
+				"flag":   true,
+				"tags":   []interface{}{"a", "b"},
+			},
+			CreatedAt: 1704000000,
+			UpdatedAt: 1704100000,
+		},
+		{
+			Title:     "Buy  milk\nand bread",
+			CreatedAt: 1704000000,
+			UpdatedAt: 1704000000,
+		},
+		{
+			Title:     "x marks the spot",
+			CreatedAt: 1704000001,
+			UpdatedAt: 1704000002,
+		},
+	}
+}
+
+func TestTasksRoundTrip(t *testing.T) {
+	for format := range TaskExportFormats {
+		tasks := exportTestTasks()
+
+		var buffer bytes.Buffer
+		if err := WriteTasks(&buffer, format, tasks); err != nil {
+			t.Fatalf("%s: WriteTasks: %v", format, err)
+		}
+
+		rows, err := ReadTasks(&buffer, format)
+		if err != nil {
+			t.Fatalf("%s: ReadTasks: %v", format, err)
+		}
+		if len(rows) != len(tasks) {
+			t.Fatalf("%s: read %d rows, want %d", format, len(rows), len(tasks))
+		}
+
+		for i, row := range rows {
+			if row.Err != nil {
+				t.Errorf("%s: row %d: %v", format, row.Row, row.Err)
+				continue
+			}
+			if !reflect.DeepEqual(*row.Task, tasks[i]) {
+				t.Errorf("%s: task %d = %+v, want %+v", format, i, *row.Task, tasks[i])
+			}
+		}
+	}
+}
+
+func TestReadTasksWithoutTimestamps(t *testing.T) {
+	rows, err := ReadTasks(strings.NewReader("Call mom\nCall mom due:2024-01-05\n"), "todotxt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("read %d rows, want 2", len(rows))
+	}
+	for _, row := range rows {
+		if row.Err != nil || row.Task.Title != "Call mom" {
+			t.Errorf("row %d = %+v, %v", row.Row, row.Task, row.Err)
+			continue
+		}
+		// Rows without a creation time are not matched against other tasks
+		if row.Task.CreatedAt != 0 || row.Task.UpdatedAt != 0 {
+			t.Errorf("row %d has timestamps %d, %d", row.Row, row.Task.CreatedAt, row.Task.UpdatedAt)
+		}
+	}
+	if due := rows[1].Task.DueAt; due == nil || *due != 1704412800 {
+		t.Errorf("due_at = %v", due)
+	}
+}
+
+func TestReadTasksInvalidRows(t *testing.T) {
+	rows, err := ReadTasks(strings.NewReader(`[{"title": ""}, {"title": "ok", "unknown": 1}, {"title": "ok"}]`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 || rows[0].Err == nil || rows[1].Err == nil || rows[2].Err != nil {
+		t.Errorf("rows = %+v", rows)
+	}
+}
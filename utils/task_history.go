@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// TaskStateOf func to take the tracked fields out of a task.
+func TaskStateOf(task *models.Task) *models.TaskState {
+	state := &models.TaskState{
+		Title:     task.Title,
+		Completed: task.Completed,
+	}
+
+	if len(task.Metadata) > 0 {
+		state.Metadata = make(map[string]interface{}, len(task.Metadata))
+		for key, value := range task.Metadata {
+			state.Metadata[key] = value
+		}
+	}
+
+	return state
+}
+
+// DiffTaskStates func to list the fields that differ between two states of a
+// task. A nil before is a created task and a nil after a deleted one.
+func DiffTaskStates(before, after *models.TaskState) []models.FieldChange {
+	changes := []models.FieldChange{}
+
+	var beforeTitle, afterTitle, beforeCompleted, afterCompleted interface{}
+	beforeMetadata, afterMetadata := map[string]interface{}{}, map[string]interface{}{}
+	if before != nil {
+		beforeTitle, beforeCompleted = before.Title, before.Completed
+		if before.Metadata != nil {
+			beforeMetadata = before.Metadata
+		}
+	}
+	if after != nil {
+		afterTitle, afterCompleted = after.Title, after.Completed
+		if after.Metadata != nil {
+			afterMetadata = after.Metadata
+		}
+	}
+
+	if beforeTitle != afterTitle {
+		changes = append(changes, models.FieldChange{Field: "title", Before: beforeTitle, After: afterTitle})
+	}
+	if beforeCompleted != afterCompleted {
+		changes = append(changes, models.FieldChange{Field: "completed", Before: beforeCompleted, After: afterCompleted})
+	}
+
+	keys := []string{}
+	for key := range beforeMetadata {
+		keys = append(keys, key)
+	}
+	for key := range afterMetadata {
+		if _, ok := beforeMetadata[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		beforeValue, hadBefore := beforeMetadata[key]
+		afterValue, hasAfter := afterMetadata[key]
+		if hadBefore == hasAfter && sameValue(beforeValue, afterValue) {
+			continue
+		}
+
+		change := models.FieldChange{Field: "metadata." + key}
+		if hadBefore {
+			change.Before = beforeValue
+		}
+		if hasAfter {
+			change.After = afterValue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// sameValue func to compare metadata values by their JSON, so a list read back
+// from Mongo equals the one that was written and a date equals its number.
+func sameValue(a, b interface{}) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
@@ -1,9 +1,16 @@
 package utils
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
-func UpdateTaskParser(taskUpdate map[string]interface{}, existingMetadata map[string]string) map[string]interface{} {
-	allowedKeys := []string{"title", "completed", "metadata"}
+// UpdateTaskParser func to check an update request and merge its metadata
+// into the existing one. Keys that can not be updated are returned as
+// FieldErrors, like PATCH does, rather than dropped without a word.
+func UpdateTaskParser(taskUpdate map[string]interface{}, existingMetadata map[string]interface{}) (map[string]interface{}, error) {
+	allowedKeys := []string{"title", "completed", "metadata", "start_at", "due_at", "time_zone", "recurrence"}
+	fieldErrors := FieldErrors{}
 	for key := range taskUpdate {
 		validKey := false
 		for _, allowedKey := range allowedKeys {
@@ -12,17 +19,94 @@ func UpdateTaskParser(taskUpdate map[string]interface{}, existingMetadata map[st
 			}
 		}
 		if !validKey {
-			delete(taskUpdate, key)
+			fieldErrors[key] = "Unknown field"
 		}
 	}
-	
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
 	if _, ok := taskUpdate["metadata"]; ok {
-		for key, value := range taskUpdate["metadata"].(map[string]interface{}) {
-			existingMetadata[key] = value.(string)
+		if existingMetadata == nil {
+			existingMetadata = map[string]interface{}{}
+		}
+		metadata, ok := taskUpdate["metadata"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("metadata must be an object")
+		}
+		// Values are checked against the custom fields later, null removes a key
+		for key, value := range metadata {
+			if value == nil {
+				delete(existingMetadata, key)
+			} else {
+				existingMetadata[key] = value
+			}
 		}
 		taskUpdate["metadata"] = existingMetadata
 	}
 
+	// Dates are unix timestamps, null clears them
+	for _, key := range []string{"start_at", "due_at"} {
+		value, ok := taskUpdate[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		timestamp, ok := value.(float64)
+		if !ok || timestamp <= 0 || timestamp != float64(int64(timestamp)) {
+			return nil, errors.New(key + " must be a unix timestamp or null")
+		}
+		taskUpdate[key] = int64(timestamp)
+	}
+
+	// Time zones are IANA names, null or an empty name clears it
+	if value, ok := taskUpdate["time_zone"]; ok && value != nil {
+		name, ok := value.(string)
+		if !ok {
+			return nil, errors.New("time_zone must be a time zone name or null")
+		}
+		if name == "" {
+			taskUpdate["time_zone"] = nil
+		} else if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+			return nil, errors.New("Unknown time_zone: " + name)
+		}
+	}
+
+	if value, ok := taskUpdate["recurrence"]; ok && value != nil {
+		rule, ok := value.(string)
+		if !ok {
+			return nil, errors.New("recurrence must be a recurrence rule or null")
+		}
+		if _, err := ParseRRule(rule); err != nil {
+			return nil, err
+		}
+	}
+
 	taskUpdate["updated_at"] = time.Now().Unix()
-	return taskUpdate
-}
\ No newline at end of file
+	return taskUpdate, nil
+}
+
+// CheckTaskDates func to make sure a task does not start after it is due.
+func CheckTaskDates(startAt, dueAt *int64) error {
+	if startAt != nil && dueAt != nil && *startAt > *dueAt {
+		return errors.New("start_at must not be after due_at")
+	}
+
+	return nil
+}
+
+// TaskDateAfterUpdate func to return the value a task date will have once a
+// parsed update is applied.
+func TaskDateAfterUpdate(taskUpdate map[string]interface{}, key string, current *int64) *int64 {
+	value, ok := taskUpdate[key]
+	if !ok {
+		return current
+	}
+
+	timestamp, ok := value.(int64)
+	if !ok {
+		return nil
+	}
+
+	return &timestamp
+}
@@ -0,0 +1,12 @@
+package utils
+
+import "testing"
+
+func TestUpdateTaskParserUnknownFields(t *testing.T) {
+	_, err := UpdateTaskParser(map[string]interface{}{"title": "Call mom", "priority": "high"}, nil)
+
+	fieldErrors, ok := err.(FieldErrors)
+	if !ok || len(fieldErrors) != 1 || fieldErrors["priority"] != "Unknown field" {
+		t.Errorf("UpdateTaskParser error = %v, want priority to be unknown", err)
+	}
+}
@@ -0,0 +1,366 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// PatchOperation struct to describe one operation of a JSON Patch. Value is
+// kept raw so an explicit null can be told apart from a missing value.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// PatchError struct to describe the operation of a JSON Patch that could not
+// be applied. Failed is set when a test operation did not match.
+type PatchError struct {
+	Index   int
+	Message string
+	Failed  bool
+}
+
+func (e *PatchError) Error() string {
+	return e.Message
+}
+
+// Fields of a task that can be patched
+var taskPatchFields = []string{"title", "completed", "metadata", "start_at", "due_at", "time_zone", "recurrence"}
+
+// TaskDocument func to render the patchable fields of a task as the JSON
+// document patches are applied to. Metadata is always an object so keys can be
+// added to it with a JSON Patch.
+func TaskDocument(task *models.Task) map[string]interface{} {
+	// Values read from Mongo become the plain JSON values a patch holds
+	metadata := map[string]interface{}{}
+	if raw, err := json.Marshal(task.Metadata); err == nil {
+		json.Unmarshal(raw, &metadata)
+	}
+
+	doc := map[string]interface{}{
+		"title":     task.Title,
+		"completed": task.Completed,
+		"metadata":  metadata,
+	}
+	if task.StartAt != nil {
+		doc["start_at"] = float64(*task.StartAt)
+	}
+	if task.DueAt != nil {
+		doc["due_at"] = float64(*task.DueAt)
+	}
+	if task.TimeZone != "" {
+		doc["time_zone"] = task.TimeZone
+	}
+	if task.Recurrence != "" {
+		doc["recurrence"] = task.Recurrence
+	}
+
+	return doc
+}
+
+// MergePatch func to apply an RFC 7396 merge patch, where null removes a key.
+func MergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = MergePatch(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+// ApplyJSONPatch func to apply the add, remove, replace and test operations of
+// an RFC 6902 JSON Patch in order. Any failed operation fails the whole patch.
+func ApplyJSONPatch(doc interface{}, operations []PatchOperation) (interface{}, error) {
+	for i, operation := range operations {
+		tokens, err := parsePointer(operation.Path)
+		if err != nil {
+			return nil, &PatchError{Index: i, Message: err.Error()}
+		}
+
+		var value interface{}
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return nil, &PatchError{Index: i, Message: operation.Op + " needs a value"}
+			}
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return nil, &PatchError{Index: i, Message: err.Error()}
+			}
+		case "remove":
+		default:
+			return nil, &PatchError{Index: i, Message: "Unsupported patch operation: " + operation.Op}
+		}
+
+		if operation.Op == "test" {
+			current, err := pointerValue(doc, tokens)
+			if err != nil {
+				return nil, &PatchError{Index: i, Message: err.Error(), Failed: true}
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, &PatchError{Index: i, Message: "Test failed at " + operation.Path, Failed: true}
+			}
+			continue
+		}
+
+		if doc, err = patchAt(doc, tokens, operation.Op, value); err != nil {
+			return nil, &PatchError{Index: i, Message: err.Error() + ": " + operation.Path}
+		}
+	}
+
+	return doc, nil
+}
+
+// TaskPatchUpdate func to validate a patched task document and turn it into
+// the fields to set, the way UpdateTaskParser does. Only the fields the patch
+// changed are returned. Problems are reported per field, metadata values are
+// left to CheckTaskMetadata.
+func TaskPatchUpdate(original, patched interface{}) (map[string]interface{}, FieldErrors) {
+	doc, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, FieldErrors{"": "Task must be an object"}
+	}
+
+	fields, fieldErrors := taskPatchValues(doc)
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
+	current, _ := taskPatchValues(original.(map[string]interface{}))
+
+	taskUpdate := map[string]interface{}{}
+	for _, field := range taskPatchFields {
+		if !reflect.DeepEqual(current[field], fields[field]) {
+			taskUpdate[field] = fields[field]
+		}
+	}
+
+	return taskUpdate, nil
+}
+
+func taskPatchValues(doc map[string]interface{}) (map[string]interface{}, FieldErrors) {
+	fields := map[string]interface{}{}
+	fieldErrors := FieldErrors{}
+
+	for key := range doc {
+		known := false
+		for _, field := range taskPatchFields {
+			known = known || key == field
+		}
+		if !known {
+			fieldErrors[key] = "Unknown field"
+		}
+	}
+
+	if title, ok := doc["title"].(string); ok && strings.TrimSpace(title) != "" {
+		fields["title"] = title
+	} else {
+		fieldErrors["title"] = "must be a non-empty string"
+	}
+
+	if completed, ok := doc["completed"].(bool); ok {
+		fields["completed"] = completed
+	} else {
+		fieldErrors["completed"] = "must be true or false"
+	}
+
+	fields["metadata"] = nil
+	switch metadata := doc["metadata"].(type) {
+	case nil:
+	case map[string]interface{}:
+		if len(metadata) > 0 {
+			fields["metadata"] = metadata
+		}
+	default:
+		fieldErrors["metadata"] = "must be an object"
+	}
+
+	for _, key := range []string{"start_at", "due_at"} {
+		fields[key] = nil
+		switch value := doc[key].(type) {
+		case nil:
+		case float64:
+			if value <= 0 || value != float64(int64(value)) {
+				fieldErrors[key] = "must be a unix timestamp or null"
+				continue
+			}
+			fields[key] = int64(value)
+		default:
+			fieldErrors[key] = "must be a unix timestamp or null"
+		}
+	}
+
+	if startAt, ok := fields["start_at"].(int64); ok {
+		if dueAt, ok := fields["due_at"].(int64); ok && startAt > dueAt {
+			fieldErrors["start_at"] = "must not be after due_at"
+		}
+	}
+
+	fields["time_zone"] = nil
+	switch name := doc["time_zone"].(type) {
+	case nil:
+	case string:
+		if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+			fieldErrors["time_zone"] = "must be a time zone name or null"
+		} else if name != "" {
+			fields["time_zone"] = name
+		}
+	default:
+		fieldErrors["time_zone"] = "must be a time zone name or null"
+	}
+
+	fields["recurrence"] = nil
+	switch rule := doc["recurrence"].(type) {
+	case nil:
+	case string:
+		if _, err := ParseRRule(rule); err != nil {
+			fieldErrors["recurrence"] = err.Error()
+		} else {
+			fields["recurrence"] = rule
+		}
+	default:
+		fieldErrors["recurrence"] = "must be a recurrence rule or null"
+	}
+
+	return fields, fieldErrors
+}
+
+// parsePointer func to split an RFC 6901 JSON Pointer into its reference tokens.
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("Invalid JSON Pointer: " + path)
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func pointerValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, errors.New("Path not found")
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, errors.New("Path not found")
+		}
+	}
+
+	return doc, nil
+}
+
+// patchAt func to apply one add, remove or replace at the path of tokens and
+// return the changed document.
+func patchAt(doc interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, errors.New("The whole task can not be removed")
+		}
+		return value, nil
+	}
+
+	token, last := tokens[0], len(tokens) == 1
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, exists := node[token]
+		if !last {
+			if !exists {
+				return nil, errors.New("Path not found")
+			}
+			updated, err := patchAt(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			node[token] = updated
+			return node, nil
+		}
+
+		if !exists && op != "add" {
+			return nil, errors.New("Path not found")
+		}
+		if op == "remove" {
+			delete(node, token)
+		} else {
+			node[token] = value
+		}
+		return node, nil
+
+	case []interface{}:
+		if last && op == "add" {
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+
+		index, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		if !last {
+			if node[index], err = patchAt(node[index], tokens[1:], op, value); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+		if op == "remove" {
+			return append(node[:index], node[index+1:]...), nil
+		}
+		node[index] = value
+		return node, nil
+	}
+
+	return nil, errors.New("Path not found")
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.New("Invalid array index: " + token)
+	}
+
+	return index, nil
+}
@@ -0,0 +1,358 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// TaskQuery struct to describe the filter and sort order of a task listing.
+type TaskQuery struct {
+	Filter bson.M
+	Sort   []SortField
+}
+
+var (
+	// Query parameters that are handled outside of the filter
+	taskPageParams = map[string]bool{"limit": true, "after": true, "before": true, "sort": true}
+
+	// Fields that can be sorted on
+	taskSortFields = map[string]bool{"created_at": true, "updated_at": true, "title": true, "completed": true, "start_at": true, "due_at": true}
+
+	// Numeric fields that can be filtered with gt, gte, lt and lte
+	taskRangeFields = map[string]bool{"created_at": true, "updated_at": true, "start_at": true, "due_at": true}
+
+	rangeOperators = map[string]string{"gt": "$gt", "gte": "$gte", "lt": "$lt", "lte": "$lte"}
+
+	metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+const maxSortFields = 3
+
+// DefaultTaskSort is the listing order used when no sort is requested.
+var DefaultTaskSort = []SortField{{Field: "created_at", Direction: -1}}
+
+// ParseTaskQuery func to turn whitelisted query parameters into a Mongo filter
+// and sort order. Unknown parameters are rejected instead of being ignored.
+// Metadata keys with a custom field are filtered and sorted by its type.
+func ParseTaskQuery(params map[string]string, fields []models.CustomField) (*TaskQuery, error) {
+	query := &TaskQuery{Filter: bson.M{}, Sort: DefaultTaskSort}
+	conditions := bson.A{}
+
+	byName := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byName[fields[i].Name] = &fields[i]
+	}
+
+	for key, value := range params {
+		if taskPageParams[key] {
+			continue
+		}
+
+		switch {
+		case key == "completed":
+			completed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, errors.New("completed must be true or false")
+			}
+			conditions = append(conditions, bson.M{"completed": completed})
+
+		case key == "title":
+			if value == "" {
+				return nil, errors.New("title must not be empty")
+			}
+			conditions = append(conditions, bson.M{"title": bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}})
+
+		case key == "parent_id":
+			if value == "none" {
+				conditions = append(conditions, bson.M{"parent_id": nil})
+				continue
+			}
+			parentID, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return nil, errors.New("parent_id must be a task ID or none")
+			}
+			conditions = append(conditions, bson.M{"parent_id": parentID})
+
+		case key == "project_id":
+			projectID, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return nil, errors.New("project_id must be a project ID")
+			}
+			conditions = append(conditions, bson.M{"project_id": projectID})
+
+		case key == "labels":
+			condition, err := parseLabelsFilter(value, params["labels_match"])
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+
+		case key == "labels_match":
+			if _, ok := params["labels"]; !ok {
+				return nil, errors.New("labels_match can only be used together with labels")
+			}
+
+		case key == "due":
+			location, err := parseTimezone(params["tz"])
+			if err != nil {
+				return nil, err
+			}
+			condition, err := parseDueFilter(value, time.Now().In(location))
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+
+		case key == "tz":
+			if _, ok := params["due"]; !ok {
+				return nil, errors.New("tz can only be used together with due")
+			}
+
+		case strings.HasPrefix(key, "metadata."):
+			condition, err := parseMetadataFilter(strings.TrimPrefix(key, "metadata."), value, byName)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+
+		default:
+			condition, err := parseRangeFilter(key, value)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+
+	if len(conditions) > 0 {
+		query.Filter = bson.M{"$and": conditions}
+	}
+
+	if sort, ok := params["sort"]; ok {
+		sortFields, err := parseTaskSort(sort, byName)
+		if err != nil {
+			return nil, err
+		}
+		query.Sort = sortFields
+	}
+
+	return query, nil
+}
+
+// parseRangeFilter func to handle parameters like created_at.gte=1680000000.
+func parseRangeFilter(key, value string) (bson.M, error) {
+	field, operator, found := strings.Cut(key, ".")
+	if !found || !taskRangeFields[field] || rangeOperators[operator] == "" {
+		return nil, errors.New("Unknown query parameter: " + key)
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.New(key + " must be a unix timestamp")
+	}
+
+	return bson.M{field: bson.M{rangeOperators[operator]: number}}, nil
+}
+
+// parseLabelsFilter func to handle labels=<id>,<id> with labels_match=any
+// (the default) or labels_match=all.
+func parseLabelsFilter(value, match string) (bson.M, error) {
+	ids := bson.A{}
+	for _, hexID := range strings.Split(value, ",") {
+		id, err := primitive.ObjectIDFromHex(hexID)
+		if err != nil {
+			return nil, errors.New("labels must be a comma separated list of label IDs")
+		}
+		ids = append(ids, id)
+	}
+
+	switch match {
+	case "", "any":
+		return bson.M{"labels": bson.M{"$in": ids}}, nil
+	case "all":
+		return bson.M{"labels": bson.M{"$all": ids}}, nil
+	}
+
+	return nil, errors.New("labels_match must be any or all")
+}
+
+// parseTimezone func to load the IANA timezone due filters are computed in.
+func parseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("Unknown timezone: " + name)
+	}
+
+	return location, nil
+}
+
+// parseDueFilter func to handle due=overdue|today|week. Days and weeks are
+// calendar days in the timezone of now, weeks start on Monday.
+func parseDueFilter(value string, now time.Time) (bson.M, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch value {
+	case "overdue":
+		return bson.M{"due_at": bson.M{"$lt": now.Unix()}, "completed": false}, nil
+	case "today":
+		return bson.M{"due_at": bson.M{"$gte": today.Unix(), "$lt": today.AddDate(0, 0, 1).Unix()}}, nil
+	case "week":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return bson.M{"due_at": bson.M{"$gte": monday.Unix(), "$lt": monday.AddDate(0, 0, 7).Unix()}}, nil
+	}
+
+	return nil, errors.New("due must be one of overdue, today or week")
+}
+
+// parseMetadataFilter func to handle metadata.<key>=value for an exact match
+// and metadata.<key>.prefix=value for a prefix match. Keys with a custom field
+// are matched by its type: numbers and dates also take gt, gte, lt and lte,
+// and enum and multi-select values take a comma separated list of options.
+func parseMetadataFilter(key, value string, fields map[string]*models.CustomField) (bson.M, error) {
+	name, operator, _ := strings.Cut(key, ".")
+	if !metadataKeyPattern.MatchString(name) {
+		return nil, errors.New("Invalid metadata key: " + name)
+	}
+
+	field := "metadata." + name
+
+	if customField, ok := fields[name]; ok {
+		return parseFieldFilter(customField, operator, value)
+	}
+
+	switch operator {
+	case "":
+		return bson.M{field: value}, nil
+	case "prefix":
+		if value == "" {
+			return nil, errors.New("metadata." + key + " must not be empty")
+		}
+		return bson.M{field: bson.M{"$regex": "^" + regexp.QuoteMeta(value)}}, nil
+	}
+
+	return nil, errors.New("Unknown query parameter: metadata." + key)
+}
+
+// parseFieldFilter func to filter on the metadata key of a custom field.
+func parseFieldFilter(customField *models.CustomField, operator, value string) (bson.M, error) {
+	field := "metadata." + customField.Name
+	key := field
+	if operator != "" {
+		key += "." + operator
+	}
+
+	switch customField.Type {
+	case models.FieldText:
+		switch operator {
+		case "":
+			return bson.M{field: value}, nil
+		case "prefix":
+			if value == "" {
+				return nil, errors.New(key + " must not be empty")
+			}
+			return bson.M{field: bson.M{"$regex": "^" + regexp.QuoteMeta(value)}}, nil
+		}
+
+	case models.FieldNumber, models.FieldDate:
+		if operator != "" && rangeOperators[operator] == "" {
+			break
+		}
+
+		number, err := parseFieldNumber(customField, value)
+		if err != nil {
+			return nil, errors.New(key + " " + err.Error())
+		}
+
+		if operator == "" {
+			return bson.M{field: number}, nil
+		}
+		return bson.M{field: bson.M{rangeOperators[operator]: number}}, nil
+
+	case models.FieldBool:
+		if operator == "" {
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, errors.New(key + " must be true or false")
+			}
+			return bson.M{field: flag}, nil
+		}
+
+	case models.FieldEnum, models.FieldMultiSelect:
+		if operator == "" {
+			options := bson.A{}
+			for _, option := range strings.Split(value, ",") {
+				if !hasOption(customField, option) {
+					return nil, errors.New(key + " must be one of " + strings.Join(customField.Options, ", "))
+				}
+				options = append(options, option)
+			}
+			return bson.M{field: bson.M{"$in": options}}, nil
+		}
+	}
+
+	return nil, errors.New("Unknown query parameter: " + key)
+}
+
+// parseFieldNumber func to read the value of a number or date field from the
+// query string, dates are compared as the unix timestamps they are stored as.
+func parseFieldNumber(customField *models.CustomField, value string) (interface{}, error) {
+	if customField.Type == models.FieldDate {
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("must be a unix timestamp")
+		}
+		return timestamp, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, errors.New("must be a number")
+	}
+
+	return number, nil
+}
+
+// parseTaskSort func to handle sort=-updated_at,title. Custom fields sort as
+// metadata.<key>, except multi-select fields which hold lists.
+func parseTaskSort(sort string, customFields map[string]*models.CustomField) ([]SortField, error) {
+	fields := []SortField{}
+	seen := map[string]bool{}
+
+	for _, key := range strings.Split(sort, ",") {
+		direction := 1
+		if strings.HasPrefix(key, "-") {
+			direction = -1
+			key = key[1:]
+		}
+
+		customField, isCustom := customFields[strings.TrimPrefix(key, "metadata.")]
+		isCustom = isCustom && strings.HasPrefix(key, "metadata.") && customField.Type != models.FieldMultiSelect
+		if !taskSortFields[key] && !isCustom {
+			return nil, errors.New("Cannot sort on " + key)
+		}
+		if seen[key] {
+			return nil, errors.New("Duplicate sort field " + key)
+		}
+		seen[key] = true
+
+		fields = append(fields, SortField{Field: key, Direction: direction})
+	}
+
+	if len(fields) > maxSortFields {
+		return nil, errors.New("At most " + strconv.Itoa(maxSortFields) + " sort fields are allowed")
+	}
+
+	return fields, nil
+}
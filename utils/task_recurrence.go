@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/roshanpaturkar/go-tasks/models"
+)
+
+// RecurrenceAnchor func to return the date a recurring task repeats on: its
+// due date, else its start date, else the moment it was created.
+func RecurrenceAnchor(task *models.Task) int64 {
+	if task.DueAt != nil {
+		return *task.DueAt
+	}
+	if task.StartAt != nil {
+		return *task.StartAt
+	}
+
+	return task.CreatedAt
+}
+
+// RecurrenceLocation func to return the time zone a recurring task repeats in.
+// All-day and floating dates keep their wall clock in UTC, other dates follow
+// the time zone of the task so weekdays and times of day stay put.
+func RecurrenceLocation(task *models.Task) *time.Location {
+	form := task.StartForm
+	if task.DueAt != nil {
+		form = task.DueForm
+	}
+	if task.TimeZone == "" || form == models.DateFormDate || form == models.DateFormFloating {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(task.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// TaskOccurrences func to list up to n occurrences of a recurring task after
+// its current one, as unix timestamps.
+func TaskOccurrences(task *models.Task, n int) ([]int64, error) {
+	rrule, err := ParseRRule(task.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := RecurrenceAnchor(task)
+	start := anchor
+	if task.SeriesStart != nil {
+		start = *task.SeriesStart
+	}
+
+	location := RecurrenceLocation(task)
+	occurrences := []int64{}
+	for _, occurrence := range rrule.Occurrences(time.Unix(start, 0).In(location), time.Unix(anchor, 0).In(location), n) {
+		occurrences = append(occurrences, occurrence.Unix())
+	}
+
+	return occurrences, nil
+}
+
+// NextTaskOccurrence func to build the task for the next occurrence of a
+// recurring task, or nil when the series is over. Dates are shifted by the
+// distance between the occurrences and metadata is carried over. The
+// occurrence is recorded so each one is only created once per series.
+func NextTaskOccurrence(task *models.Task) (*models.Task, error) {
+	occurrences, err := TaskOccurrences(task, 1)
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+
+	timestamp := time.Now().Unix()
+	shift := occurrences[0] - RecurrenceAnchor(task)
+
+	seriesID := task.ID
+	if task.SeriesId != nil {
+		seriesID = *task.SeriesId
+	}
+
+	seriesStart := RecurrenceAnchor(task)
+	if task.SeriesStart != nil {
+		seriesStart = *task.SeriesStart
+	}
+
+	next := &models.Task{
+		UserId:       task.UserId,
+		Title:        task.Title,
+		SearchText:   TaskSearchText(task.Metadata),
+		ParentId:     task.ParentId,
+		ProjectId:    task.ProjectId,
+		Labels:       task.Labels,
+		StartForm:    task.StartForm,
+		DueForm:      task.DueForm,
+		TimeZone:     task.TimeZone,
+		Recurrence:   task.Recurrence,
+		SeriesStart:  &seriesStart,
+		SeriesId:     &seriesID,
+		OccurrenceAt: &occurrences[0],
+		CreatedBy:    task.CreatedBy,
+		Version:      1,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+	}
+
+	if task.Metadata != nil {
+		next.Metadata = map[string]interface{}{}
+		for key, value := range task.Metadata {
+			next.Metadata[key] = value
+		}
+	}
+
+	if task.StartAt != nil {
+		startAt := *task.StartAt + shift
+		next.StartAt = &startAt
+	}
+
+	// Tasks without dates repeat on their creation time, the next one gets
+	// the occurrence as due date so the series does not drift
+	if task.DueAt != nil || task.StartAt == nil {
+		dueAt := occurrences[0]
+		next.DueAt = &dueAt
+	}
+
+	return next, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaskSearchText func to gather the text of the metadata values of a task,
+// strings and lists of strings, for the search index. Keys and other fields
+// of the task are left out so searches only match what users wrote.
+func TaskSearchText(metadata map[string]interface{}) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	texts := []string{}
+	for _, key := range keys {
+		switch value := metadata[key].(type) {
+		case string:
+			texts = append(texts, value)
+		case []string:
+			texts = append(texts, value...)
+		case []interface{}:
+			texts = append(texts, searchStrings(value)...)
+		case primitive.A:
+			texts = append(texts, searchStrings(value)...)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+func searchStrings(values []interface{}) []string {
+	texts := []string{}
+	for _, value := range values {
+		if text, ok := value.(string); ok {
+			texts = append(texts, text)
+		}
+	}
+
+	return texts
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTaskSearchText(t *testing.T) {
+	metadata := map[string]interface{}{
+		"owner":  "Sam",
+		"points": float64(3),
+		"tags":   primitive.A{"garden", int32(4)},
+		"done":   true,
+		"note":   "water daily",
+	}
+
+	if got, want := TaskSearchText(metadata), "water daily\nSam\ngarden"; got != want {
+		t.Errorf("TaskSearchText = %q, want %q", got, want)
+	}
+	if got := TaskSearchText(nil); got != "" {
+		t.Errorf("TaskSearchText(nil) = %q", got)
+	}
+}
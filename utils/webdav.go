@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+	NamespaceAppleICal      = "http://apple.com/ns/ical/"
+)
+
+// Prefixes of the namespaces declared on every multistatus, prop values use
+// them for their elements.
+var davPrefixes = map[string]string{
+	NamespaceDAV:            "D",
+	NamespaceCalDAV:         "C",
+	NamespaceCalendarServer: "CS",
+	NamespaceAppleICal:      "A",
+}
+
+// DavProp is a property of a WebDAV resource. Value is XML that may use the
+// D, C, CS and A prefixes.
+type DavProp struct {
+	Name  xml.Name
+	Value string
+}
+
+// DavResponse is one response of a multistatus, the properties of a resource
+// or, when Status is set, only a status for its href.
+type DavResponse struct {
+	Href    string
+	Props   []DavProp
+	Missing []xml.Name
+	Status  int
+}
+
+// DavRequest is the body of a PROPFIND or REPORT. Type is its root element,
+// AllProp is set when it asks for every property.
+type DavRequest struct {
+	Type    xml.Name
+	Props   []xml.Name
+	AllProp bool
+	Hrefs   []string
+	Filter  TodoFilter
+}
+
+// TodoFilter is the part of a calendar-query filter tasks are matched by. A
+// query for other components matches nothing, time ranges are not applied.
+type TodoFilter struct {
+	NoTodos   bool
+	Completed *bool
+}
+
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// ParseDavRequest func to read the body of a PROPFIND or REPORT. An empty
+// PROPFIND asks for every property.
+func ParseDavRequest(body []byte) (*DavRequest, error) {
+	request := &DavRequest{}
+	if len(bytes.TrimSpace(body)) == 0 {
+		request.Type = xml.Name{Space: NamespaceDAV, Local: "propfind"}
+		request.AllProp = true
+		return request, nil
+	}
+
+	var root xmlNode
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, errors.New("request body is not valid XML")
+	}
+	request.Type = root.XMLName
+
+	for _, node := range root.Nodes {
+		switch node.XMLName {
+		case xml.Name{Space: NamespaceDAV, Local: "allprop"}, xml.Name{Space: NamespaceDAV, Local: "propname"}:
+			request.AllProp = true
+		case xml.Name{Space: NamespaceDAV, Local: "prop"}:
+			for _, prop := range node.Nodes {
+				request.Props = append(request.Props, prop.XMLName)
+			}
+		case xml.Name{Space: NamespaceDAV, Local: "href"}:
+			request.Hrefs = append(request.Hrefs, strings.TrimSpace(node.Text))
+		case xml.Name{Space: NamespaceCalDAV, Local: "filter"}:
+			request.Filter = todoFilter(node)
+		}
+	}
+
+	return request, nil
+}
+
+// todoFilter func to read the comp-filter of a calendar-query. Clients ask for
+// open tasks with COMPLETED not defined or a STATUS other than COMPLETED.
+func todoFilter(filter xmlNode) TodoFilter {
+	todoFilter := TodoFilter{}
+
+	for _, calendar := range davChildren(filter, NamespaceCalDAV, "comp-filter") {
+		for _, component := range davChildren(calendar, NamespaceCalDAV, "comp-filter") {
+			if !strings.EqualFold(davAttr(component, "name"), "VTODO") {
+				if len(davChildren(component, NamespaceCalDAV, "is-not-defined")) == 0 {
+					todoFilter.NoTodos = true
+				}
+				continue
+			}
+
+			for _, property := range davChildren(component, NamespaceCalDAV, "prop-filter") {
+				switch strings.ToUpper(davAttr(property, "name")) {
+				case "COMPLETED":
+					completed := len(davChildren(property, NamespaceCalDAV, "is-not-defined")) == 0
+					todoFilter.Completed = &completed
+				case "STATUS":
+					for _, match := range davChildren(property, NamespaceCalDAV, "text-match") {
+						if strings.EqualFold(strings.TrimSpace(match.Text), "COMPLETED") {
+							completed := davAttr(match, "negate-condition") != "yes"
+							todoFilter.Completed = &completed
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return todoFilter
+}
+
+func davChildren(node xmlNode, space, local string) []xmlNode {
+	var children []xmlNode
+	for _, child := range node.Nodes {
+		if child.XMLName.Space == space && child.XMLName.Local == local {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+func davAttr(node xmlNode, name string) string {
+	for _, attr := range node.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// DavProps func to pick the properties a request asks for. Properties the
+// resource does not have are returned as missing, AllProp returns them all.
+func DavProps(request *DavRequest, props []DavProp) ([]DavProp, []xml.Name) {
+	if request.AllProp {
+		return props, nil
+	}
+
+	var found []DavProp
+	var missing []xml.Name
+	for _, name := range request.Props {
+		ok := false
+		for _, prop := range props {
+			if prop.Name == name {
+				found = append(found, prop)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	return found, missing
+}
+
+// WriteMultistatus func to write a 207 Multi-Status body.
+func WriteMultistatus(w io.Writer, responses []DavResponse) error {
+	var body strings.Builder
+
+	body.WriteString(xml.Header)
+	body.WriteString(`<D:multistatus` + davNamespaces() + `>`)
+	for _, response := range responses {
+		body.WriteString(`<D:response>`)
+		body.WriteString(DavHref(response.Href))
+
+		if response.Status != 0 {
+			body.WriteString(davStatus(response.Status))
+			body.WriteString(`</D:response>`)
+			continue
+		}
+
+		if len(response.Props) > 0 || len(response.Missing) == 0 {
+			body.WriteString(`<D:propstat><D:prop>`)
+			for _, prop := range response.Props {
+				body.WriteString(DavElement(prop.Name, prop.Value))
+			}
+			body.WriteString(`</D:prop>` + davStatus(http.StatusOK) + `</D:propstat>`)
+		}
+
+		if len(response.Missing) > 0 {
+			body.WriteString(`<D:propstat><D:prop>`)
+			for _, name := range response.Missing {
+				body.WriteString(DavElement(name, ""))
+			}
+			body.WriteString(`</D:prop>` + davStatus(http.StatusNotFound) + `</D:propstat>`)
+		}
+
+		body.WriteString(`</D:response>`)
+	}
+	body.WriteString(`</D:multistatus>`)
+
+	_, err := io.WriteString(w, body.String())
+	return err
+}
+
+// DavErrorBody func to write the body of an error response naming the
+// precondition that failed.
+func DavErrorBody(condition xml.Name, value string) string {
+	return xml.Header + `<D:error` + davNamespaces() + `>` + DavElement(condition, value) + `</D:error>`
+}
+
+// DavElement func to render an element in its namespace around value.
+// Namespaces without a prefix are declared on the element.
+func DavElement(name xml.Name, value string) string {
+	tag := name.Local
+	declaration := ""
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "X:" + name.Local
+		declaration = ` xmlns:X="` + DavText(name.Space) + `"`
+	}
+
+	if value == "" {
+		return "<" + tag + declaration + "/>"
+	}
+
+	return "<" + tag + declaration + ">" + value + "</" + tag + ">"
+}
+
+// DavHref func to render an href element.
+func DavHref(href string) string {
+	return `<D:href>` + DavText(href) + `</D:href>`
+}
+
+// DavText func to escape text for an XML value.
+func DavText(text string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+
+	return escaped.String()
+}
+
+func davStatus(status int) string {
+	return `<D:status>HTTP/1.1 ` + strconv.Itoa(status) + ` ` + http.StatusText(status) + `</D:status>`
+}
+
+func davNamespaces() string {
+	declarations := ""
+	for _, space := range []string{NamespaceDAV, NamespaceCalDAV, NamespaceCalendarServer, NamespaceAppleICal} {
+		declarations += ` xmlns:` + davPrefixes[space] + `="` + space + `"`
+	}
+
+	return declarations
+}